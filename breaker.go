@@ -0,0 +1,223 @@
+package req
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of the circuit breaker of a host.
+type BreakerState int
+
+const (
+	// BreakerClosed lets the requests through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails the requests fast with a *CircuitOpenError.
+	BreakerOpen
+	// BreakerHalfOpen lets one request through to probe the host.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// CircuitOpenError is returned by Do when the circuit breaker of the
+// host is open.
+type CircuitOpenError struct {
+	Host string
+	// Until is when the breaker lets a request through again.
+	Until time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("req: circuit breaker of %s is open until %s", e.Host, e.Until.Format(time.RFC3339))
+}
+
+// CircuitBreaker fails the requests to a host fast once Threshold
+// requests in a row failed, with a transport error or one of the
+// FailureStatus codes. After Cooldown, one request is let through:
+// its success closes the breaker, its failure opens it again.
+//
+// The fields must not be changed once the breaker is in use.
+type CircuitBreaker struct {
+	// Threshold is the number of consecutive failures opening the
+	// breaker of a host.
+	Threshold int
+	// Cooldown is how long the breaker stays open.
+	Cooldown time.Duration
+	// FailureStatus are the status codes counted as failures, every
+	// status of 500 and above if empty.
+	FailureStatus []int
+	// OnStateChange, if set, is called when the state of the breaker
+	// of host changes, e.g. to update metrics. It may be called from
+	// several goroutines.
+	OnStateChange func(host string, from, to BreakerState)
+
+	mu    sync.Mutex
+	hosts map[string]*breakerHost
+}
+
+type breakerHost struct {
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// probing is set while the request of a half-open breaker is sent
+	probing bool
+}
+
+// NewCircuitBreaker creates a new *CircuitBreaker opening after threshold
+// consecutive failures for cooldown.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown}
+}
+
+// SetCircuitBreaker sets the circuit breaker of the requests of r,
+// nil removes it. A breaker can be shared by several Req.
+func (r *Req) SetCircuitBreaker(cb *CircuitBreaker) {
+	r.mu.Lock()
+	r.breaker = cb
+	r.mu.Unlock()
+}
+
+func (r *Req) getCircuitBreaker() *CircuitBreaker {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.breaker
+}
+
+// State returns the state of the breaker of host, like "example.com:443"
+// or "example.com" for the default port.
+func (cb *CircuitBreaker) State(host string) BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if h, ok := cb.hosts[strings.ToLower(host)]; ok {
+		return cb.current(h)
+	}
+	return BreakerClosed
+}
+
+// States returns the state of the breaker of every host requested.
+func (cb *CircuitBreaker) States() map[string]BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	states := make(map[string]BreakerState, len(cb.hosts))
+	for host, h := range cb.hosts {
+		states[host] = cb.current(h)
+	}
+	return states
+}
+
+// current returns the state of h, an open breaker is reported half-open
+// once the cooldown is over.
+func (cb *CircuitBreaker) current(h *breakerHost) BreakerState {
+	if h.state == BreakerOpen && time.Since(h.openedAt) >= cb.Cooldown {
+		return BreakerHalfOpen
+	}
+	return h.state
+}
+
+// setState must be called with cb.mu held, the returned function
+// notifies the change once cb.mu is released.
+func (cb *CircuitBreaker) setState(host string, h *breakerHost, state BreakerState) func() {
+	from := h.state
+	h.state = state
+	if state == BreakerOpen {
+		h.openedAt = time.Now()
+	}
+	return func() {
+		if from != state && cb.OnStateChange != nil {
+			cb.OnStateChange(host, from, state)
+		}
+	}
+}
+
+// allow returns a *CircuitOpenError if a request to host must fail fast.
+// The outcome of an allowed request must be reported with done.
+func (cb *CircuitBreaker) allow(host string) error {
+	cb.mu.Lock()
+	if cb.hosts == nil {
+		cb.hosts = make(map[string]*breakerHost)
+	}
+	h, ok := cb.hosts[host]
+	if !ok {
+		h = &breakerHost{}
+		cb.hosts[host] = h
+	}
+	switch cb.current(h) {
+	case BreakerOpen:
+		until := h.openedAt.Add(cb.Cooldown)
+		cb.mu.Unlock()
+		return &CircuitOpenError{Host: host, Until: until}
+	case BreakerHalfOpen:
+		if h.probing {
+			cb.mu.Unlock()
+			return &CircuitOpenError{Host: host, Until: time.Now().Add(cb.Cooldown)}
+		}
+		notify := cb.setState(host, h, BreakerHalfOpen)
+		h.probing = true
+		cb.mu.Unlock()
+		notify()
+		return nil
+	}
+	cb.mu.Unlock()
+	return nil
+}
+
+// done reports the outcome of a request to host allowed by allow. A
+// request which was not sent, or canceled by its context, is not
+// counted.
+func (cb *CircuitBreaker) done(host string, ctx context.Context, response *http.Response, err error) {
+	counted, failed := true, false
+	switch {
+	case err != nil && response == nil:
+		// canceled by the caller, or stopped by a hook before being sent
+		_, hook := err.(*hookError)
+		counted = !hook && ctx.Err() == nil
+		failed = true
+	case response != nil:
+		failed = cb.isFailure(response.StatusCode)
+	}
+
+	notify := func() {}
+	cb.mu.Lock()
+	h := cb.hosts[host]
+	probe := h.probing
+	h.probing = false
+	switch {
+	case !counted:
+	case !failed:
+		h.failures = 0
+		notify = cb.setState(host, h, BreakerClosed)
+	default:
+		h.failures++
+		if probe || h.failures >= cb.Threshold {
+			notify = cb.setState(host, h, BreakerOpen)
+		}
+	}
+	cb.mu.Unlock()
+	notify()
+}
+
+func (cb *CircuitBreaker) isFailure(status int) bool {
+	if len(cb.FailureStatus) == 0 {
+		return status >= 500
+	}
+	for _, s := range cb.FailureStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
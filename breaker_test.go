@@ -0,0 +1,119 @@
+package req
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var status, hits int32 = http.StatusInternalServerError, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	var changes []string
+	cb := NewCircuitBreaker(2, 100*time.Millisecond)
+	cb.OnStateChange = func(host string, from, to BreakerState) {
+		changes = append(changes, from.String()+">"+to.String())
+	}
+	r := New()
+	r.SetCircuitBreaker(cb)
+	for i := 0; i < 2; i++ {
+		if _, err := r.Get(ts.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if state := cb.State(u.Host); state != BreakerOpen {
+		t.Errorf("state = %v; want = open", state)
+	}
+	_, err := r.Get(ts.URL)
+	if e, ok := err.(*CircuitOpenError); !ok || e.Host != u.Host {
+		t.Errorf("error = %v; want a *CircuitOpenError", err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("hits = %d; want = 2, the open breaker fails fast", n)
+	}
+
+	// the probe fails, the breaker opens again
+	time.Sleep(120 * time.Millisecond)
+	if state := cb.State(u.Host); state != BreakerHalfOpen {
+		t.Errorf("state = %v; want = half-open", state)
+	}
+	if _, err = r.Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.Get(ts.URL); err == nil {
+		t.Error("the breaker did not open again after a failed probe")
+	}
+
+	// the probe succeeds, the breaker closes
+	time.Sleep(120 * time.Millisecond)
+	atomic.StoreInt32(&status, http.StatusOK)
+	for i := 0; i < 3; i++ {
+		if _, err = r.Get(ts.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if states := cb.States(); states[u.Host] != BreakerClosed {
+		t.Errorf("states = %v; want the breaker closed", states)
+	}
+	want := []string{"closed>open", "open>half-open", "half-open>open", "open>half-open", "half-open>closed"}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %v; want = %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("changes = %v; want = %v", changes, want)
+			break
+		}
+	}
+}
+
+func TestCircuitBreakerFailures(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/busy" {
+			w.WriteHeader(http.StatusTooManyRequests)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	u, _ := url.Parse(ts.URL)
+
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.FailureStatus = []int{http.StatusTooManyRequests}
+	r := New()
+	r.SetCircuitBreaker(cb)
+	for i := 0; i < 3; i++ {
+		if _, err := r.Get(ts.URL + "/error"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if state := cb.State(u.Host); state != BreakerClosed {
+		t.Errorf("state = %v; want 500 not counted", state)
+	}
+	_, _ = r.Get(ts.URL + "/busy")
+	_, _ = r.Get(ts.URL + "/busy")
+	if state := cb.State(u.Host); state != BreakerOpen {
+		t.Errorf("state = %v; want 429 counted", state)
+	}
+
+	// transport errors are failures too
+	ts.Close()
+	cb = NewCircuitBreaker(1, time.Minute)
+	r.SetCircuitBreaker(cb)
+	if _, err := r.Get(ts.URL); err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+	if _, err := r.Get(ts.URL); err == nil {
+		t.Fatal("request succeeded")
+	} else if _, ok := err.(*CircuitOpenError); !ok {
+		t.Errorf("error = %v; want a *CircuitOpenError", err)
+	}
+}
@@ -0,0 +1,607 @@
+package req
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CurlCommand returns a curl command line sending the same request as
+// r.Do(method, rawurl, v...), without sending it. Like Do, it consumes
+// the request body and closes the uploaded files.
+//
+// The command holds the credentials of the request, it is not redacted.
+func (r *Req) CurlCommand(method, rawurl string, v ...interface{}) (string, error) {
+	resp, _, _, lastFunc, err := r.build(method, rawurl, v)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		for _, fn := range lastFunc {
+			fn()
+		}
+	}()
+	req := resp.req
+	if resp.multipartHelper == nil && resp.reqBody == nil && req.Body != nil {
+		// a reader body is not read until the request is sent
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		resp.reqBody = body
+	}
+	if jar := resp.client.Jar; jar != nil {
+		req = req.Clone(req.Context())
+		for _, c := range jar.Cookies(req.URL) {
+			req.AddCookie(c)
+		}
+	}
+	return resp.curlCommand(req)
+}
+
+// CurlCommand returns a curl command line reproducing the request of r,
+// including the cookies of the cookie jar and the proxy and insecure TLS
+// settings of the transport.
+//
+// The command holds the credentials of the request, it is not redacted.
+// An error is returned if the request body was read from an io.Reader
+// and only its beginning was kept, see Req.CurlCommand.
+func (r *Resp) CurlCommand() (string, error) {
+	req := r.req
+	if r.resp != nil && r.resp.Request != nil {
+		// the request as sent, with the cookies of the jar, before
+		// any redirect
+		req = r.resp.Request
+		for req.Response != nil && req.Response.Request != nil {
+			req = req.Response.Request
+		}
+	}
+	return r.curlCommand(req)
+}
+
+func (r *Resp) curlCommand(req *http.Request) (string, error) {
+	body := r.requestBody()
+	if bw := r.reqBodyWrapper; bw != nil && bw.n > len(body) {
+		return "", errors.New("req: request body not kept in full for the curl command")
+	}
+	args := []string{"curl"}
+	m := r.multipartHelper
+	hasBody := len(body) > 0 || m != nil
+	if (req.Method != "GET" || hasBody) && !(req.Method == "POST" && hasBody) {
+		if req.Method == "HEAD" {
+			args = append(args, "-I")
+		} else {
+			args = append(args, "-X", req.Method)
+		}
+	}
+	args = append(args, shellQuote(req.URL.String()))
+
+	header := req.Header.Clone()
+	if username, password, ok := req.BasicAuth(); ok {
+		header.Del("Authorization")
+		args = append(args, "-u", shellQuote(username+":"+password))
+	}
+	if req.Host != "" && req.Host != req.URL.Host {
+		header.Set("Host", req.Host)
+	}
+	cookie := strings.Join(header.Values("Cookie"), "; ")
+	header.Del("Cookie")
+	header.Del("Content-Length")
+	if m != nil {
+		// curl writes its own boundary
+		header.Del("Content-Type")
+	}
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range header[key] {
+			args = append(args, "-H", shellQuote(key+": "+value))
+		}
+	}
+	if cookie != "" {
+		args = append(args, "-b", shellQuote(cookie))
+	}
+
+	if m != nil {
+		keys = keys[:0]
+		for key := range m.form {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, value := range m.form[key] {
+				args = append(args, "--form-string", shellQuote(key+"="+value))
+			}
+		}
+		for _, up := range m.uploads {
+			path := up.FileName
+			if f, ok := up.File.(*os.File); ok {
+				path = f.Name()
+			}
+			field := up.FieldName + "=@" + path
+			if up.FileName != "" && up.FileName != path {
+				field += ";filename=" + up.FileName
+			}
+			if up.ContentType != "" {
+				field += ";type=" + up.ContentType
+			}
+			args = append(args, "-F", shellQuote(field))
+		}
+	} else if len(body) > 0 {
+		args = append(args, "--data-raw", shellQuote(string(body)))
+	}
+
+	if r.client != nil {
+		if r.client.CheckRedirect == nil {
+			args = append(args, "-L")
+		}
+		if trans, ok := r.client.Transport.(*http.Transport); ok {
+			if trans.Proxy != nil {
+				if u, err := trans.Proxy(req); err == nil && u != nil {
+					args = append(args, "-x", shellQuote(u.String()))
+				}
+			}
+			if trans.TLSClientConfig != nil && trans.TLSClientConfig.InsecureSkipVerify {
+				args = append(args, "-k")
+			}
+		}
+	}
+	return strings.Join(args, " "), nil
+}
+
+// shellQuote quotes s for a POSIX shell, unless it is made of safe
+// characters only.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_./:=@,+%", c))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Curl is a request parsed from a curl command line, like the ones copied
+// with "Copy as cURL" from the devtools of browsers.
+type Curl struct {
+	Method string
+	URL    string
+	// Options are the Header, *http.Cookie, BasicAuth, body, Param and
+	// FileUpload values of the command, to pass to Req.Do. The form of
+	// a command with -F is always sent as multipart/form-data.
+	Options []interface{}
+}
+
+// curlFlags maps the supported curl options taking an argument to their
+// long names.
+var curlFlags = map[string]string{
+	"-X": "--request", "--request": "--request",
+	"-H": "--header", "--header": "--header",
+	"-d": "--data", "--data": "--data", "--data-ascii": "--data",
+	"--data-raw": "--data-raw", "--data-binary": "--data-binary",
+	"--data-urlencode": "--data-urlencode", "--json": "--json",
+	"-F": "--form", "--form": "--form", "--form-string": "--form-string",
+	"-u": "--user", "--user": "--user",
+	"-b": "--cookie", "--cookie": "--cookie",
+	"-A": "--user-agent", "--user-agent": "--user-agent",
+	"-e": "--referer", "--referer": "--referer",
+	"--url": "--url",
+	// ignored, they only change how curl itself behaves
+	"-o": "--output", "--output": "--output",
+	"-m": "--max-time", "--max-time": "--max-time",
+	"--connect-timeout": "--connect-timeout",
+	"-x":                "--proxy", "--proxy": "--proxy",
+}
+
+// curlSwitches maps the supported curl options without argument to their
+// long names.
+var curlSwitches = map[string]string{
+	"-G": "--get", "--get": "--get",
+	"-I": "--head", "--head": "--head",
+	// ignored, they only change how curl itself behaves
+	"-k": "", "--insecure": "",
+	"-L": "", "--location": "",
+	"-s": "", "--silent": "",
+	"-S": "", "--show-error": "",
+	"-v": "", "--verbose": "",
+	"-i": "", "--include": "",
+	"-f": "", "--fail": "",
+	"--compressed": "",
+	"--http1.1":    "",
+	"--http2":      "",
+}
+
+// ParseCurl parses a curl command line, in the syntax of a POSIX shell.
+// The files of the uploads are opened, Req.Do closes them.
+func ParseCurl(command string) (*Curl, error) {
+	args, err := splitShell(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, errors.New("req: not a curl command")
+	}
+
+	c := &Curl{}
+	header := make(http.Header)
+	var data []string
+	// the form files are opened once the whole command is checked
+	var files [][2]string
+	params := Param{}
+	method := ""
+	get := false
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			c.URL = arg
+			continue
+		}
+		name, value, attached := arg, "", false
+		if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			if _, ok := curlFlags[arg[:2]]; ok {
+				// -XPOST
+				name, value, attached = arg[:2], arg[2:], true
+			} else {
+				// clustered switches like -sSL
+				for _, s := range arg[1:] {
+					if _, ok := curlSwitches["-"+string(s)]; !ok {
+						return nil, fmt.Errorf("req: unsupported curl option %s", arg)
+					}
+				}
+				for _, s := range arg[1:] {
+					curlSwitch(curlSwitches["-"+string(s)], &method, &get)
+				}
+				continue
+			}
+		}
+		if long, ok := curlSwitches[name]; ok {
+			curlSwitch(long, &method, &get)
+			continue
+		}
+		long, ok := curlFlags[name]
+		if !ok {
+			return nil, fmt.Errorf("req: unsupported curl option %s", arg)
+		}
+		if !attached {
+			if i++; i >= len(args) {
+				return nil, fmt.Errorf("req: curl option %s needs an argument", arg)
+			}
+			value = args[i]
+		}
+		switch long {
+		case "--request":
+			method = value
+		case "--url":
+			c.URL = value
+		case "--header":
+			colon := strings.IndexByte(value, ':')
+			if colon <= 0 {
+				return nil, fmt.Errorf("req: invalid curl header %q", value)
+			}
+			header.Add(strings.TrimSpace(value[:colon]), strings.TrimSpace(value[colon+1:]))
+		case "--user-agent":
+			header.Set("User-Agent", value)
+		case "--referer":
+			header.Set("Referer", value)
+		case "--user":
+			username, password := value, ""
+			if colon := strings.IndexByte(value, ':'); colon >= 0 {
+				username, password = value[:colon], value[colon+1:]
+			}
+			c.Options = append(c.Options, BasicAuth{Username: username, Password: password})
+		case "--cookie":
+			if !strings.Contains(value, "=") {
+				return nil, fmt.Errorf("req: curl cookie files are not supported: %s", value)
+			}
+			for _, pair := range strings.Split(value, ";") {
+				if pair = strings.TrimSpace(pair); pair == "" {
+					continue
+				}
+				eq := strings.IndexByte(pair, '=')
+				if eq < 0 {
+					eq = len(pair)
+					pair += "="
+				}
+				c.Options = append(c.Options, &http.Cookie{Name: pair[:eq], Value: pair[eq+1:]})
+			}
+		case "--data", "--data-binary", "--json":
+			if strings.HasPrefix(value, "@") {
+				content, err := ioutil.ReadFile(value[1:])
+				if err != nil {
+					return nil, err
+				}
+				value = string(content)
+				if long == "--data" {
+					// like curl, --data strips the newlines of files
+					value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+				}
+			}
+			if long == "--json" {
+				setDefaultHeader(header, "Content-Type", "application/json")
+				setDefaultHeader(header, "Accept", "application/json")
+			}
+			data = append(data, value)
+		case "--data-raw":
+			data = append(data, value)
+		case "--data-urlencode":
+			if eq := strings.IndexByte(value, '='); eq >= 0 {
+				value = value[:eq+1] + url.QueryEscape(value[eq+1:])
+			} else {
+				value = url.QueryEscape(value)
+			}
+			data = append(data, value)
+		case "--form", "--form-string":
+			eq := strings.IndexByte(value, '=')
+			if eq <= 0 {
+				return nil, fmt.Errorf("req: invalid curl form field %q", value)
+			}
+			field, content := value[:eq], value[eq+1:]
+			if long == "--form-string" || !strings.HasPrefix(content, "@") {
+				params[field] = content
+				continue
+			}
+			files = append(files, [2]string{field, content[1:]})
+		}
+	}
+	if c.URL == "" {
+		return nil, errors.New("req: url not specified")
+	}
+	multipart := len(files) > 0 || len(params) > 0
+	if multipart && get {
+		// curl refuses them as well
+		return nil, errors.New("req: curl form fields can not be sent with -G")
+	}
+	var form []FileUpload
+	for _, file := range files {
+		up, err := curlFormFile(file[0], file[1])
+		if err != nil {
+			for _, up := range form {
+				_ = up.File.Close()
+			}
+			return nil, err
+		}
+		form = append(form, up)
+	}
+
+	if len(header) > 0 {
+		c.Options = append(c.Options, header)
+	}
+	body := strings.Join(data, "&")
+	switch {
+	case get && len(data) > 0:
+		if strings.IndexByte(c.URL, '?') == -1 {
+			c.URL += "?" + body
+		} else {
+			c.URL += "&" + body
+		}
+	case multipart:
+		// the form is multipart even without files, like curl sends it
+		c.Options = append(c.Options, multipartForm{})
+		if len(params) > 0 {
+			c.Options = append(c.Options, params)
+		}
+		if len(form) > 0 {
+			c.Options = append(c.Options, form)
+		}
+		if method == "" {
+			method = "POST"
+		}
+	case len(data) > 0:
+		if header.Get("Content-Type") == "" {
+			c.Options = append(c.Options, FormData(body))
+		} else {
+			c.Options = append(c.Options, []byte(body))
+		}
+		if method == "" {
+			method = "POST"
+		}
+	}
+	if method == "" {
+		method = "GET"
+	}
+	c.Method = method
+	return c, nil
+}
+
+func curlSwitch(long string, method *string, get *bool) {
+	switch long {
+	case "--get":
+		*get = true
+	case "--head":
+		*method = "HEAD"
+	}
+}
+
+func setDefaultHeader(header http.Header, key, value string) {
+	if header.Get(key) == "" {
+		header.Set(key, value)
+	}
+}
+
+// curlFormFile opens the file of a curl form field like
+// "name=@path;type=text/plain;filename=a.txt".
+func curlFormFile(field, value string) (FileUpload, error) {
+	parts := strings.Split(value, ";")
+	up := FileUpload{FieldName: field, FileName: filepath.Base(parts[0])}
+	for _, part := range parts[1:] {
+		key, v := part, ""
+		if eq := strings.IndexByte(part, '='); eq >= 0 {
+			key, v = part[:eq], strings.Trim(part[eq+1:], `"`)
+		}
+		switch key {
+		case "type":
+			up.ContentType = v
+		case "filename":
+			up.FileName = v
+		}
+	}
+	file, err := os.Open(parts[0])
+	if err != nil {
+		return FileUpload{}, err
+	}
+	up.File = file
+	return up, nil
+}
+
+// DoCurl parses a curl command line and executes it, v are additional
+// options of Do.
+func (r *Req) DoCurl(command string, v ...interface{}) (*Resp, error) {
+	c, err := ParseCurl(command)
+	if err != nil {
+		return nil, err
+	}
+	return r.Do(c.Method, c.URL, append(c.Options, v...)...)
+}
+
+// DoCurl parses a curl command line and executes it.
+func DoCurl(command string, v ...interface{}) (*Resp, error) {
+	r := newShared()
+	return r.DoCurl(command, v...)
+}
+
+// splitShell splits a command line into words like a POSIX shell,
+// handling quotes, escapes, line continuations and $'...' strings.
+func splitShell(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			if i+1 < len(s) {
+				i++
+				if s[i] == '\n' {
+					// line continuation
+					continue
+				}
+				if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+					i++
+					continue
+				}
+				word.WriteByte(s[i])
+			}
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("req: unterminated quote")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\\\"$`\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New("req: unterminated quote")
+			}
+			inWord = true
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			n, err := unquoteANSIC(s[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 2
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// unquoteANSIC writes the content of a $'...' string to w, s starts after
+// the opening quote. It returns the index of the closing quote.
+func unquoteANSIC(s string, w *strings.Builder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return i, nil
+		}
+		if c != '\\' || i+1 >= len(s) {
+			w.WriteByte(c)
+			continue
+		}
+		i++
+		switch c = s[i]; c {
+		case 'n':
+			w.WriteByte('\n')
+		case 't':
+			w.WriteByte('\t')
+		case 'r':
+			w.WriteByte('\r')
+		case 'a':
+			w.WriteByte('\a')
+		case 'b':
+			w.WriteByte('\b')
+		case 'e', 'E':
+			w.WriteByte(0x1b)
+		case 'f':
+			w.WriteByte('\f')
+		case 'v':
+			w.WriteByte('\v')
+		case 'x', 'u', 'U':
+			size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+			j := i + 1
+			for j < len(s) && j-i-1 < size && isHex(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				w.WriteByte('\\')
+				w.WriteByte(c)
+				continue
+			}
+			n, _ := strconv.ParseUint(s[i+1:j], 16, 32)
+			if c == 'x' {
+				w.WriteByte(byte(n))
+			} else {
+				w.WriteRune(rune(n))
+			}
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(s) && j-i < 3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			n, _ := strconv.ParseUint(s[i:j], 8, 8)
+			w.WriteByte(byte(n))
+			i = j - 1
+		default:
+			// \\ \' \" and \?
+			w.WriteByte(c)
+		}
+	}
+	return 0, errors.New("req: unterminated quote")
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
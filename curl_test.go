@@ -0,0 +1,216 @@
+package req
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCurlCommand(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+	}))
+	defer ts.Close()
+
+	r := New()
+	r.EnableInsecureTLS(true)
+	r.SetBasicAuth("roc", "pass")
+	if err := r.SetProxyUrl("http://proxy.example.com:8080"); err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := r.CurlCommand("PUT", ts.URL+"/put", Header{"X-Name": "it's"}, BodyJSON(map[string]string{"name": "roc"}),
+		&http.Cookie{Name: "id", Value: "1"}, QueryParam{"page": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"curl -X PUT '" + ts.URL + "/put?page=1'",
+		"-u roc:pass",
+		`-H 'X-Name: it'\''s'`,
+		"-H 'Content-Type: application/json; charset=UTF-8'",
+		"-b id=1",
+		`--data-raw '{"name":"roc"}'`,
+		"-x http://proxy.example.com:8080",
+		"-k",
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("command = %s; want it to contain %s", cmd, want)
+		}
+	}
+	if strings.Contains(cmd, "Authorization") {
+		t.Errorf("command = %s; want the basic auth as -u", cmd)
+	}
+
+	r = New()
+	r.EnableInsecureTLS(false)
+	resp, err := r.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd, _ = resp.CurlCommand(); strings.Contains(cmd, "-X") || strings.Contains(cmd, " -k") || strings.Contains(cmd, " -b ") {
+		t.Errorf("command = %s; want a plain GET", cmd)
+	}
+	// the jar cookie is sent along
+	resp, err = r.Post(ts.URL, Param{"name": "roc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd, err = resp.CurlCommand(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(cmd, "curl "+ts.URL+" ") || !strings.Contains(cmd, "-b session=abc") || !strings.Contains(cmd, "--data-raw name=roc") {
+		t.Errorf("command = %s; want a POST with the session cookie", cmd)
+	}
+
+	cmd, err = r.CurlCommand("POST", ts.URL, Param{"name": "roc"}, FileUpload{File: stringFile("hello"), FieldName: "media", FileName: "a.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(cmd, "--form-string name=roc -F media=@a.txt") || strings.Contains(cmd, "Content-Type") {
+		t.Errorf("command = %s; want multipart fields", cmd)
+	}
+
+	// a reader body is only kept up to a limit
+	resp, err = r.Post(ts.URL, strings.NewReader("small body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd, err = resp.CurlCommand(); err != nil || !strings.Contains(cmd, "--data-raw 'small body'") {
+		t.Errorf("command = %s, %v; want the reader body", cmd, err)
+	}
+	resp, err = r.Post(ts.URL, strings.NewReader(strings.Repeat("x", 200*1024)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd, err = resp.CurlCommand(); err == nil {
+		t.Errorf("command = %.100s...; want an error for the truncated body", cmd)
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	words, err := splitShell(`curl 'a b' "c \"d\" \$e" f\ g $'h\'i\nj\x41é' \
+  -k`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"curl", "a b", `c "d" $e`, "f g", "h'i\njAé", "-k"}
+	if strings.Join(words, "|") != strings.Join(want, "|") {
+		t.Errorf("words = %q; want = %q", words, want)
+	}
+	if _, err = ParseCurl("curl 'http://example.com"); err == nil {
+		t.Error("unterminated quote parsed")
+	}
+	if _, err = ParseCurl("curl --unknown http://example.com"); err == nil {
+		t.Error("unknown option parsed")
+	}
+	// the form files are opened only once the command is checked
+	_, err = ParseCurl("curl http://example.com -F 'media=@missing.txt' --unknown")
+	if err == nil || !strings.Contains(err.Error(), "--unknown") {
+		t.Errorf("err = %v; want the unsupported option", err)
+	}
+	if _, err = ParseCurl("curl -F 'media=@missing.txt'"); err == nil || !strings.Contains(err.Error(), "url") {
+		t.Errorf("err = %v; want url not specified", err)
+	}
+	if _, err = ParseCurl("curl http://example.com -G -F name=roc"); err == nil {
+		t.Error("form parsed with -G")
+	}
+
+	c, err := ParseCurl(`curl 'http://example.com/search?q=1' -G --data-urlencode 'name=a b' -sSL`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Method != "GET" || c.URL != "http://example.com/search?q=1&name=a+b" {
+		t.Errorf("request = %s %s; want the data in the query", c.Method, c.URL)
+	}
+}
+
+func TestDoCurl(t *testing.T) {
+	type received struct {
+		method, contentType, cookie, auth, custom, body string
+		file                                            string
+	}
+	var got received
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = received{
+			method:      r.Method,
+			contentType: r.Header.Get("Content-Type"),
+			cookie:      r.Header.Get("Cookie"),
+			custom:      r.Header.Get("X-Custom"),
+		}
+		if username, password, ok := r.BasicAuth(); ok {
+			got.auth = username + ":" + password
+		}
+		if strings.HasPrefix(got.contentType, "multipart/form-data") {
+			got.body = r.FormValue("name")
+			if file, header, err := r.FormFile("media"); err == nil {
+				content, _ := ioutil.ReadAll(file)
+				got.file = header.Filename + ":" + string(content)
+			}
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		got.body = string(body)
+	}))
+	defer ts.Close()
+
+	// as copied from the devtools of a browser
+	command := `curl '` + ts.URL + `/api' \
+  -H 'content-type: application/json' \
+  -H 'x-custom: it'\''s' \
+  -b 'a=1; b=2' \
+  -u roc:pass \
+  --data-raw $'{"name":"r\'oc"}' \
+  --compressed`
+	r := New()
+	if _, err := r.DoCurl(command); err != nil {
+		t.Fatal(err)
+	}
+	want := received{
+		method:      "POST",
+		contentType: "application/json",
+		cookie:      "a=1; b=2",
+		auth:        "roc:pass",
+		custom:      "it's",
+		body:        `{"name":"r'oc"}`,
+	}
+	if got != want {
+		t.Errorf("received = %+v; want = %+v", got, want)
+	}
+
+	if _, err := r.DoCurl(`curl -X PUT ` + ts.URL + ` -d name=roc`); err != nil {
+		t.Fatal(err)
+	}
+	if got.method != "PUT" || got.contentType != "application/x-www-form-urlencoded" || got.body != "name=roc" {
+		t.Errorf("received = %+v; want a PUT form", got)
+	}
+
+	name := filepath.Join(t.TempDir(), "hello.txt")
+	if err := ioutil.WriteFile(name, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.DoCurl(`curl ` + ts.URL + ` -F name=roc -F 'media=@` + name + `;type=text/plain'`); err != nil {
+		t.Fatal(err)
+	}
+	if got.method != "POST" || got.body != "roc" || got.file != "hello.txt:hello" {
+		t.Errorf("received = %+v; want a multipart upload", got)
+	}
+	// like curl, -F sends a multipart form even without files
+	if _, err := r.DoCurl(`curl ` + ts.URL + ` -F name=roc`); err != nil {
+		t.Fatal(err)
+	}
+	if got.method != "POST" || !strings.HasPrefix(got.contentType, "multipart/form-data") || got.body != "roc" {
+		t.Errorf("received = %+v; want a multipart form", got)
+	}
+	if _, err := r.DoCurl(`curl -X PATCH ` + ts.URL + ` -F 'media=@` + name + `'`); err != nil {
+		t.Fatal(err)
+	}
+	if got.method != "PATCH" || got.file != "hello.txt:hello" {
+		t.Errorf("received = %+v; want a PATCH upload", got)
+	}
+	if _, err := r.DoCurl(`curl -X GET ` + ts.URL + ` -F name=roc`); err == nil {
+		t.Error("GET with a multipart form sent")
+	}
+}
@@ -0,0 +1,125 @@
+package req
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// contentEncodings returns the content codings of header, in the order
+// they were applied.
+func contentEncodings(header http.Header) []string {
+	var encodings []string
+	for _, value := range header.Values("Content-Encoding") {
+		for _, enc := range strings.Split(value, ",") {
+			enc = strings.ToLower(strings.TrimSpace(enc))
+			if enc != "" && enc != "identity" {
+				encodings = append(encodings, enc)
+			}
+		}
+	}
+	return encodings
+}
+
+// decodedBody reads a body through its decoders, closing them along
+// with the body.
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if e := b.closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+type closerFunc func()
+
+func (f closerFunc) Close() error {
+	f()
+	return nil
+}
+
+// decodeBody returns a reader decoding the content codings of body, the
+// body is closed if a decoder can not be created.
+func decodeBody(body io.ReadCloser, header http.Header) (io.ReadCloser, error) {
+	encodings := contentEncodings(header)
+	if len(encodings) == 0 {
+		return body, nil
+	}
+	br := bufio.NewReader(body)
+	if _, err := br.Peek(1); err == io.EOF {
+		// e.g. the response of a HEAD request
+		return body, nil
+	}
+	decoded := &decodedBody{Reader: br, closers: []io.Closer{body}}
+	// the last coding listed was applied last
+	for i := len(encodings) - 1; i >= 0; i-- {
+		if err := decoded.decode(encodings[i]); err != nil {
+			_ = decoded.Close()
+			return nil, err
+		}
+	}
+	return decoded, nil
+}
+
+func (b *decodedBody) decode(encoding string) error {
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(b.Reader)
+		if err != nil {
+			return err
+		}
+		b.Reader = zr
+		b.closers = append(b.closers, zr)
+	case "deflate":
+		// deflate should be zlib wrapped, but many servers send it raw
+		br := bufio.NewReader(b.Reader)
+		if isZlibHeader(br) {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return err
+			}
+			b.Reader = zr
+			b.closers = append(b.closers, zr)
+		} else {
+			fr := flate.NewReader(br)
+			b.Reader = fr
+			b.closers = append(b.closers, fr)
+		}
+	case "br":
+		b.Reader = brotli.NewReader(b.Reader)
+	case "zstd":
+		zr, err := zstd.NewReader(b.Reader)
+		if err != nil {
+			return err
+		}
+		b.Reader = zr
+		b.closers = append(b.closers, closerFunc(zr.Close))
+	default:
+		return fmt.Errorf("req: unsupported content encoding %q", encoding)
+	}
+	return nil
+}
+
+// isZlibHeader reports whether br starts with a zlib header, see RFC 1950.
+func isZlibHeader(br *bufio.Reader) bool {
+	h, err := br.Peek(2)
+	if err != nil {
+		return false
+	}
+	return h[0]&0x0f == 8 && h[0]>>4 <= 7 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0
+}
@@ -0,0 +1,127 @@
+package req
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func encodeBody(t *testing.T, data []byte, encoding string) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		var err error
+		if w, err = zstd.NewWriter(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	content := []byte(strings.Repeat("hello req ", 1000))
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		// the codings in the order they are applied
+		codings := strings.Split(r.URL.Query().Get("codings"), ",")
+		body := content
+		for _, coding := range codings {
+			body = encodeBody(t, body, coding)
+			if coding == "raw-deflate" {
+				coding = "deflate"
+			}
+			w.Header().Add("Content-Encoding", coding)
+		}
+		_, _ = w.Write(body)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	// the transport does not decode gzip when Accept-Encoding is set
+	header := Header{"Accept-Encoding": "gzip, deflate, br, zstd"}
+	for _, codings := range []string{"gzip", "deflate", "raw-deflate", "br", "zstd", "deflate,gzip", "zstd,br"} {
+		resp, err := Get(ts.URL+"?codings="+codings, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := resp.ToBytes()
+		if err != nil || !bytes.Equal(data, content) {
+			t.Errorf("%s: body = %.20q, %v; want the decoded content", codings, data, err)
+		}
+
+		resp, err = Get(ts.URL+"?codings="+codings, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err = resp.LimitReaderBytes(5)
+		if err != nil || string(data) != "hello" {
+			t.Errorf("%s: limited body = %q, %v; want = hello", codings, data, err)
+		}
+		data, err = resp.LimitReaderBytes(4)
+		if err != nil || string(data) != " req" {
+			t.Errorf("%s: limited body = %q, %v; want =  req", codings, data, err)
+		}
+		resp.Close()
+
+		resp, err = Get(ts.URL+"?codings="+codings, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Join(t.TempDir(), "file")
+		if err = resp.ToFile(name); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ = ioutil.ReadFile(name); !bytes.Equal(data, content) {
+			t.Errorf("%s: file = %.20q; want the decoded content", codings, data)
+		}
+	}
+
+	resp, err := Head(ts.URL+"?codings=gzip", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := resp.ToBytes(); err != nil || len(data) != 0 {
+		t.Errorf("HEAD body = %q, %v; want empty", data, err)
+	}
+}
+
+func TestDecodeBodyUnsupported(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "compress")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	resp, err := Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = resp.ToBytes(); err == nil || !strings.Contains(err.Error(), "compress") {
+		t.Errorf("error = %v; want an unsupported encoding error", err)
+	}
+}
@@ -0,0 +1,242 @@
+package req
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultSegmentAttempts is the number of attempts made for each segment
+// of ParallelDownload when no RetryPolicy is set.
+const defaultSegmentAttempts = 3
+
+// ParallelDownload downloads rawurl into the file name, splitting it into
+// segments byte ranges fetched concurrently and written at their offsets
+// of the preallocated file. A failed segment is retried from where it
+// stopped, according to the RetryPolicy of r or the one given in v.
+// When the server does not support ranges, the file is downloaded with
+// a single request.
+func (r *Req) ParallelDownload(rawurl, name string, segments int, v ...interface{}) error {
+	if segments < 1 {
+		segments = 1
+	}
+	ctx := context.Background()
+	policy := r.getRetry()
+	var progress DownloadProgress
+	opts := make([]interface{}, 0, len(v)+3)
+	for _, vv := range v {
+		switch o := vv.(type) {
+		case context.Context:
+			ctx = o
+		case *RetryPolicy:
+			policy = o
+		case DownloadProgress:
+			progress = o
+		default:
+			opts = append(opts, vv)
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// segments handle their own retries, and must not be decompressed
+	opts = append(opts, ctx, (*RetryPolicy)(nil), Header{"Accept-Encoding": "identity"})
+
+	// probe the size of the file and whether ranges are supported
+	probe := append(opts[:len(opts):len(opts)], Header{"Range": "bytes=0-0"})
+	if progress != nil {
+		probe = append(probe, progress)
+	}
+	resp, err := r.Do("GET", rawurl, probe...)
+	if err != nil {
+		return err
+	}
+	_, _, size, ok := parseContentRange(resp.resp.Header.Get("Content-Range"))
+	if resp.resp.StatusCode != http.StatusPartialContent || !ok || size < 0 {
+		// no range support, the response holds the whole file
+		if resp.resp.StatusCode != http.StatusOK {
+			resp.Close()
+			return fmt.Errorf("req: can not download %s, status %s", rawurl, resp.resp.Status)
+		}
+		return resp.ToFile(name)
+	}
+	resp.Close()
+	// a weak ETag can not be sent as If-Range, see RFC 7233
+	validator := resp.resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = resp.resp.Header.Get("Last-Modified")
+	}
+
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = file.Truncate(size); err != nil {
+		return err
+	}
+	if size == 0 {
+		return nil
+	}
+
+	d := &parallelDownload{
+		r:         r,
+		rawurl:    rawurl,
+		file:      file,
+		opts:      opts,
+		validator: validator,
+		policy:    policy,
+		size:      size,
+		progress:  progress,
+		interval:  r.getProgressInterval(),
+	}
+	segmentSize := (size + int64(segments) - 1) / int64(segments)
+	errs := make(chan error, segments)
+	var wg sync.WaitGroup
+	for start := int64(0); start < size; start += segmentSize {
+		end := start + segmentSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := d.segment(ctx, start, end); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	close(errs)
+	if err = <-errs; err != nil {
+		return err
+	}
+	d.report(true)
+	return nil
+}
+
+type parallelDownload struct {
+	r         *Req
+	rawurl    string
+	file      *os.File
+	opts      []interface{}
+	validator string
+	policy    *RetryPolicy
+
+	mu       sync.Mutex
+	size     int64
+	current  int64
+	last     time.Time
+	progress DownloadProgress
+	interval time.Duration
+}
+
+// segment downloads the bytes from start to end, both included.
+func (d *parallelDownload) segment(ctx context.Context, start, end int64) error {
+	attempts := defaultSegmentAttempts
+	if d.policy != nil {
+		attempts = d.policy.MaxAttempts
+	}
+	var err error
+	for n := 1; ; n++ {
+		var written int64
+		written, err = d.fetch(start, end)
+		start += written
+		if err == nil || n >= attempts || ctx.Err() != nil {
+			return err
+		}
+		wait := defaultBackoff(n)
+		if d.policy != nil {
+			wait, _ = d.policy.wait(n, nil)
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// fetch requests one range and writes it at its offset of the file,
+// returning the number of bytes written.
+func (d *parallelDownload) fetch(start, end int64) (int64, error) {
+	header := Header{"Range": fmt.Sprintf("bytes=%d-%d", start, end)}
+	if d.validator != "" {
+		header["If-Range"] = d.validator
+	}
+	resp, err := d.r.Do("GET", d.rawurl, append(d.opts[:len(d.opts):len(d.opts)], header)...)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Close()
+	if resp.resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("req: range request of %s got status %s", d.rawurl, resp.resp.Status)
+	}
+	cr := resp.resp.Header.Get("Content-Range")
+	if s, _, _, ok := parseContentRange(cr); !ok || s != start {
+		return 0, &RangeMismatchError{Offset: start, ContentRange: cr}
+	}
+	var body io.Reader = resp.resp.Body
+	if resp.respBody != nil {
+		// already read, e.g. by the debug output
+		body = bytes.NewReader(resp.respBody)
+	}
+	w := &offsetWriter{file: d.file, off: start}
+	buf := make([]byte, 32*1024)
+	var written int64
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return written, werr
+			}
+			written += int64(n)
+			d.add(int64(n))
+		}
+		if err == io.EOF {
+			if written < end-start+1 {
+				return written, io.ErrUnexpectedEOF
+			}
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+func (d *parallelDownload) add(n int64) {
+	d.mu.Lock()
+	d.current += n
+	d.mu.Unlock()
+	d.report(false)
+}
+
+func (d *parallelDownload) report(force bool) {
+	if d.progress == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !force && time.Since(d.last) < d.interval {
+		return
+	}
+	d.last = time.Now()
+	d.progress(d.current, d.size)
+}
+
+// offsetWriter writes to a file from an offset.
+type offsetWriter struct {
+	file *os.File
+	off  int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.file.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
@@ -0,0 +1,127 @@
+package req
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelDownload(t *testing.T) {
+	content := strings.Repeat("0123456789abcdef", 10000)
+	var mu sync.Mutex
+	ranges := map[string]bool{}
+	var failed int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		mu.Lock()
+		ranges[rng] = true
+		mu.Unlock()
+		// the first request of the second segment fails midway
+		if strings.HasPrefix(rng, "bytes=40000-") && atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			w.Header().Set("Content-Range", "bytes 40000-79999/160000")
+			w.Header().Set("Content-Length", "40000")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte(content[40000:45000]))
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.txt", time.Time{}, strings.NewReader(content))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	r := New()
+	r.SetProgressInterval(0)
+	var current, total int64
+	progress := DownloadProgress(func(c, t int64) {
+		current, total = c, t
+	})
+	policy := NewRetryPolicy(3)
+	policy.Backoff = func(n int) time.Duration { return 0 }
+
+	name := filepath.Join(t.TempDir(), "file.txt")
+	if err := r.ParallelDownload(ts.URL, name, 4, progress, policy); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Error("file content mismatch")
+	}
+	for _, rng := range []string{"bytes=0-0", "bytes=0-39999", "bytes=40000-79999", "bytes=45000-79999", "bytes=120000-159999"} {
+		if !ranges[rng] {
+			t.Errorf("range %s not requested", rng)
+		}
+	}
+	if current != int64(len(content)) || total != int64(len(content)) {
+		t.Errorf("progress = %d/%d; want = %d/%d", current, total, len(content), len(content))
+	}
+}
+
+func TestParallelDownloadWeakETag(t *testing.T) {
+	content := strings.Repeat("0123456789abcdef", 1000)
+	modtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, lastModified := range []bool{true, false} {
+		var mu sync.Mutex
+		ifRanges := map[string]bool{}
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Range") != "bytes=0-0" {
+				mu.Lock()
+				ifRanges[r.Header.Get("If-Range")] = true
+				mu.Unlock()
+			}
+			w.Header().Set("ETag", `W/"v1"`)
+			mt := time.Time{}
+			if lastModified {
+				mt = modtime
+			}
+			http.ServeContent(w, r, "file.txt", mt, strings.NewReader(content))
+		}
+		ts := httptest.NewServer(http.HandlerFunc(handler))
+
+		name := filepath.Join(t.TempDir(), "file.txt")
+		if err := New().ParallelDownload(ts.URL, name, 4); err != nil {
+			t.Fatal(err)
+		}
+		ts.Close()
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Error("file content mismatch")
+		}
+		want := ""
+		if lastModified {
+			want = modtime.Format(http.TimeFormat)
+		}
+		if len(ifRanges) != 1 || !ifRanges[want] {
+			t.Errorf("If-Range = %v; want = %q", ifRanges, want)
+		}
+	}
+}
+
+func TestParallelDownloadWithoutRanges(t *testing.T) {
+	content := strings.Repeat("req", 1000)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(content))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	name := filepath.Join(t.TempDir(), "file.txt")
+	if err := New().ParallelDownload(ts.URL, name, 4); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(name); string(data) != content {
+		t.Error("file content mismatch")
+	}
+}
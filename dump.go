@@ -9,12 +9,12 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 	"strings"
 	"time"
 )
 
-// Debug enable debug mode if set to true
+// Debug enable debug mode of every Req if set to true,
+// see Req.SetDebug to enable it for one Req
 var Debug bool
 
 // dumpConn is a net.Conn which writes to Writer and reads from Reader
@@ -96,17 +96,17 @@ func (b *dumpBuffer) WriteString(s string) {
 }
 
 func (r *Resp) dumpRequest(dump *dumpBuffer) {
-	head := r.r.flag&LreqHead != 0
-	body := r.r.flag&LreqBody != 0
+	head := r.flag&LreqHead != 0
+	body := r.flag&LreqBody != 0
 
 	if head {
 		r.dumpReqHead(dump)
 	}
 	if body {
 		if r.multipartHelper != nil {
-			dump.Write(r.multipartHelper.Dump())
-		} else if len(r.reqBody) > 0 {
-			dump.Write(r.reqBody)
+			dump.Write(r.multipartHelper.dump(r.redaction.redactForm(r.multipartHelper.form)))
+		} else if body := r.requestBody(); len(body) > 0 {
+			dump.Write(r.redaction.redactBody(body, r.req.Header.Get("Content-Type")))
 		}
 	}
 }
@@ -114,9 +114,8 @@ func (r *Resp) dumpRequest(dump *dumpBuffer) {
 func (r *Resp) dumpReqHead(dump *dumpBuffer) {
 	reqSend := new(http.Request)
 	*reqSend = *r.req
+	reqSend.URL = r.redaction.redactURL(r.req.URL)
 	if reqSend.URL.Scheme == "https" {
-		reqSend.URL = new(url.URL)
-		*reqSend.URL = *r.req.URL
 		reqSend.URL.Scheme = "http"
 	}
 
@@ -170,13 +169,13 @@ func (r *Resp) dumpReqHead(dump *dumpBuffer) {
 		if i := bytes.Index(reqDump, []byte("\r\n\r\n")); i >= 0 {
 			reqDump = reqDump[:i]
 		}
-		dump.Write(reqDump)
+		dump.Write(r.redaction.redactHead(reqDump))
 	}
 }
 
 func (r *Resp) dumpResponse(dump *dumpBuffer) {
-	head := r.r.flag&LrespHead != 0
-	body := r.r.flag&LrespBody != 0
+	head := r.flag&LrespHead != 0
+	body := r.flag&LrespBody != 0
 	if head {
 		respDump, err := httputil.DumpResponse(r.resp, false)
 		if err != nil {
@@ -185,24 +184,29 @@ func (r *Resp) dumpResponse(dump *dumpBuffer) {
 			if i := bytes.Index(respDump, []byte("\r\n\r\n")); i >= 0 {
 				respDump = respDump[:i]
 			}
-			dump.Write(respDump)
+			dump.Write(r.redaction.redactHead(respDump))
 		}
 	}
 	if body && len(r.Bytes()) > 0 {
-		dump.Write(r.Bytes())
+		dump.Write(r.redaction.redactBody(r.Bytes(), r.resp.Header.Get("Content-Type")))
 	}
 }
 
-// Cost return the time cost of the request
+// Cost return the time cost of the request, including every attempt,
+// until the response head is received
 func (r *Resp) Cost() time.Duration {
 	return r.cost
 }
 
-// Dump dump the request
+// Dump dump the request, hiding the secrets described by the Redaction
 func (r *Resp) Dump() string {
 	dump := new(dumpBuffer)
-	if r.r.flag&Lcost != 0 {
-		dump.WriteString(fmt.Sprint(r.cost))
+	if r.flag&Lcost != 0 {
+		if r.flag&LrespBody != 0 {
+			// read the body first so that its timing is known
+			_, _ = r.ToBytes()
+		}
+		dump.WriteString(fmt.Sprintf("%v (%v)", r.cost, r.TraceInfo()))
 	}
 	r.dumpRequest(dump)
 	l := dump.Len()
@@ -213,5 +217,5 @@ func (r *Resp) Dump() string {
 
 	r.dumpResponse(dump)
 
-	return dump.String()
+	return r.redaction.redactText(dump.String())
 }
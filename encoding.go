@@ -1,12 +1,19 @@
 package req
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 
-	"github.com/axgle/mahonia"
 	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
 )
 
 var (
@@ -104,34 +111,132 @@ func GetEncoding(content []byte, headers http.Header) string {
 	return ""
 }
 
-/**
- * 编码转换
- * 需要传入原始编码和输出编码，如果原始编码传入出错，则转换出来的文本会乱码
- */
+// lookupEncoding returns the encoding of a WHATWG label like "gbk" or
+// "shift_jis", or of the charset of a content type, nil if unknown.
+func lookupEncoding(label string) encoding.Encoding {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if strings.Contains(label, "charset=") {
+		_, params := parseContentTypeHeader(label)
+		label = params["charset"]
+	}
+	e, err := htmlindex.Get(label)
+	if err != nil {
+		return nil
+	}
+	return e
+}
+
+// newDecoder returns a transformer from e to UTF-8, a byte order mark
+// overrides e.
+func newDecoder(e encoding.Encoding) transform.Transformer {
+	if e == simplifiedchinese.GBK {
+		// like browsers, decode gbk and gb2312 as their superset gb18030
+		e = simplifiedchinese.GB18030
+	}
+	return unicode.BOMOverride(e.NewDecoder())
+}
+
+// decodeToUTF8 transcodes content from e to UTF-8.
+func decodeToUTF8(content []byte, e encoding.Encoding) ([]byte, error) {
+	data, _, err := transform.Bytes(newDecoder(e), content)
+	return data, err
+}
+
+// charsetReader transcodes XML documents declaring a non UTF-8 encoding,
+// see xml.Decoder.CharsetReader.
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	e := lookupEncoding(label)
+	if e == nil {
+		return nil, fmt.Errorf("req: unsupported charset %q", label)
+	}
+	return transform.NewReader(input, newDecoder(e)), nil
+}
+
+// EncodingConvert converts src from the charset srcCode to the charset
+// tagCode, unknown charsets are left as is.
 func EncodingConvert(src string, srcCode string, tagCode string) string {
-	srcCoder := mahonia.NewDecoder(srcCode)
-	srcResult := srcCoder.ConvertString(src)
-	tagCoder := mahonia.NewDecoder(tagCode)
-	_, cdata, _ := tagCoder.Translate([]byte(srcResult), true)
-	result := string(cdata)
-	return result
+	if e := lookupEncoding(srcCode); e != nil {
+		if data, err := decodeToUTF8([]byte(src), e); err == nil {
+			src = string(data)
+		}
+	}
+	if e := lookupEncoding(tagCode); e != nil {
+		if data, _, err := transform.Bytes(e.NewEncoder(), []byte(src)); err == nil {
+			src = string(data)
+		}
+	}
+	return src
 }
 
+// EncodingConvertToUtf8 converts content from contentType, a charset or a
+// content type with a charset, to UTF-8. It supports every encoding of the
+// WHATWG Encoding Standard.
 func EncodingConvertToUtf8(content string, contentType string) string {
-	var encode string
+	e := lookupEncoding(contentType)
+	if e == nil {
+		return content
+	}
+	data, err := decodeToUTF8([]byte(content), e)
+	if err != nil {
+		return content
+	}
+	return string(data)
+}
 
-	if strings.Contains(contentType, "gbk") || strings.Contains(contentType, "gb2312") || strings.Contains(contentType, "gb18030") || strings.Contains(contentType, "windows-1252") {
-		encode = "gb18030"
-	} else if strings.Contains(contentType, "big5") {
-		encode = "big5"
-	} else if strings.Contains(contentType, "utf-8") {
-		encode = "utf-8"
+// EnableAutoCharset makes ToString, String, ToJSON and ToXML transcode the
+// response body to UTF-8 from the charset declared by the document or the
+// response header.
+func (r *Req) EnableAutoCharset(enable bool) {
+	r.mu.Lock()
+	r.autoCharset = enable
+	r.mu.Unlock()
+}
 
-	}
+func (r *Req) autoCharsetEnabled() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.autoCharset
+}
 
-	if encode != "" && encode != "utf-8" {
+// SetRequestCharset sets the charset of the Param, QueryParam, url.Values,
+// FormData and multipart form fields of every request, which are UTF-8 by
+// default. Like browsers, the characters the charset lacks are sent as
+// HTML character references.
+func (r *Req) SetRequestCharset(charset string) {
+	r.mu.Lock()
+	r.charset = charset
+	r.mu.Unlock()
+}
 
-		content = EncodingConvert(content, encode, "utf-8")
+func (r *Req) getRequestCharset() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.charset
+}
+
+// encodeCharset transcodes s from UTF-8 to e.
+func encodeCharset(s string, e encoding.Encoding) (string, error) {
+	data, _, err := transform.String(encoding.HTMLEscapeUnsupported(e.NewEncoder()), s)
+	return data, err
+}
+
+// encodeValues returns a copy of values transcoded from UTF-8 to e.
+func encodeValues(values url.Values, e encoding.Encoding) (url.Values, error) {
+	if values == nil {
+		return nil, nil
+	}
+	encoded := make(url.Values, len(values))
+	for key, vs := range values {
+		k, err := encodeCharset(key, e)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vs {
+			if v, err = encodeCharset(v, e); err != nil {
+				return nil, err
+			}
+			encoded[k] = append(encoded[k], v)
+		}
 	}
-	return content
+	return encoded, nil
 }
@@ -2,7 +2,21 @@ package req
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
 )
 
 func TestGetEncoding(t *testing.T) {
@@ -25,3 +39,186 @@ func TestGetEncoding(t *testing.T) {
 	}
 	fmt.Println(html)
 }
+
+func encodeString(t *testing.T, s string, e encoding.Encoding) string {
+	data, _, err := transform.String(e.NewEncoder(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEncodingConvertToUtf8(t *testing.T) {
+	tests := []struct {
+		charset string
+		enc     encoding.Encoding
+		text    string
+	}{
+		{"gbk", simplifiedchinese.GBK, "你好，世界"},
+		{"gb18030", simplifiedchinese.GB18030, "你好，世界"},
+		{"big5", traditionalchinese.Big5, "你好，世界"},
+		{"shift_jis", japanese.ShiftJIS, "こんにちは世界"},
+		{"euc-jp", japanese.EUCJP, "こんにちは世界"},
+		{"euc-kr", korean.EUCKR, "안녕하세요"},
+		{"windows-1252", charmap.Windows1252, "café “quoted”"},
+		{"windows-1251", charmap.Windows1251, "Привет"},
+		{"koi8-r", charmap.KOI8R, "Привет"},
+		{"text/html; charset=Shift_JIS", japanese.ShiftJIS, "日本語"},
+	}
+	for _, test := range tests {
+		encoded := encodeString(t, test.text, test.enc)
+		if got := EncodingConvertToUtf8(encoded, test.charset); got != test.text {
+			t.Errorf("%s: EncodingConvertToUtf8 = %q; want = %q", test.charset, got, test.text)
+		}
+		if got := EncodingConvert(test.text, "utf-8", test.charset); got != encoded {
+			t.Errorf("%s: EncodingConvert = %q; want = %q", test.charset, got, encoded)
+		}
+	}
+
+	// the byte order mark wins over the charset
+	utf16 := encodeString(t, "héllo", unicode.UTF16(unicode.LittleEndian, unicode.UseBOM))
+	if got := EncodingConvertToUtf8(utf16, "utf-16"); got != "héllo" {
+		t.Errorf("utf-16: EncodingConvertToUtf8 = %q; want = héllo", got)
+	}
+	if got := EncodingConvertToUtf8("hello", "unknown-charset"); got != "hello" {
+		t.Errorf("unknown charset: EncodingConvertToUtf8 = %q; want = hello", got)
+	}
+}
+
+func TestAutoCharset(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/html":
+			// declared by the document only
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(encodeString(t, `<html><head><meta charset="gbk"></head><body>你好</body></html>`, simplifiedchinese.GBK)))
+		case "/json":
+			w.Header().Set("Content-Type", "application/json; charset=Shift_JIS")
+			_, _ = w.Write([]byte(encodeString(t, `{"msg":"こんにちは"}`, japanese.ShiftJIS)))
+		case "/xml":
+			w.Header().Set("Content-Type", "text/xml")
+			_, _ = w.Write([]byte(encodeString(t, `<?xml version="1.0" encoding="euc-kr"?><result><msg>안녕</msg></result>`, korean.EUCKR)))
+		case "/large":
+			w.Header().Set("Content-Type", "text/plain; charset=koi8-r")
+			_, _ = w.Write([]byte(encodeString(t, strings.Repeat("Привет ", 10000), charmap.KOI8R)))
+		}
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	var result struct {
+		Msg string `json:"msg" xml:"msg"`
+	}
+	r := New()
+	resp, err := r.Get(ts.URL + "/html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := resp.String(); strings.Contains(s, "你好") {
+		t.Errorf("body = %q; want it left as is by default", s)
+	}
+	// the encoding declared by the document is supported anyway
+	resp, err = r.Get(ts.URL + "/xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = resp.ToXML(&result); err != nil || result.Msg != "안녕" {
+		t.Errorf("xml = %+v, %v; want = 안녕", result, err)
+	}
+
+	r.EnableAutoCharset(true)
+	resp, err = r.Get(ts.URL + "/html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := resp.ToString(); err != nil || !strings.Contains(s, "<body>你好</body>") {
+		t.Errorf("body = %q, %v; want it transcoded", s, err)
+	}
+	resp, err = r.Get(ts.URL + "/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = resp.ToJSON(&result); err != nil || result.Msg != "こんにちは" {
+		t.Errorf("json = %+v, %v; want = こんにちは", result, err)
+	}
+	resp, err = r.Get(ts.URL + "/xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = resp.ToXML(&result); err != nil || result.Msg != "안녕" {
+		t.Errorf("xml = %+v, %v; want = 안녕", result, err)
+	}
+
+	resp, err = r.Get(ts.URL + "/large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rd, err := resp.UTF8Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+	data, err := ioutil.ReadAll(rd)
+	if err != nil || string(data) != strings.Repeat("Привет ", 10000) {
+		t.Errorf("streamed body = %.30q, %v; want it transcoded", data, err)
+	}
+}
+
+func TestRequestCharset(t *testing.T) {
+	var query, contentType, body, field string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		contentType = r.Header.Get("Content-Type")
+		if strings.HasPrefix(contentType, "multipart/form-data") {
+			_ = r.ParseMultipartForm(1 << 20)
+			field = r.FormValue("name")
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	gbk := func(s string) string {
+		return encodeString(t, s, simplifiedchinese.GBK)
+	}
+	r := New()
+	r.SetRequestCharset("gbk")
+	if _, err := r.Post(ts.URL, QueryParam{"q": "中文"}, Param{"name": "张三"}); err != nil {
+		t.Fatal(err)
+	}
+	if query != "q="+url.QueryEscape(gbk("中文")) {
+		t.Errorf("query = %s; want it encoded in gbk", query)
+	}
+	if body != "name="+url.QueryEscape(gbk("张三")) || contentType != "application/x-www-form-urlencoded; charset=gbk" {
+		t.Errorf("body = %s, content type = %s; want it encoded in gbk", body, contentType)
+	}
+
+	// per request, for raw form data too
+	if _, err := r.Post(ts.URL, FormData("name=山田"), RequestCharset("shift_jis")); err != nil {
+		t.Fatal(err)
+	}
+	if body != "name="+encodeString(t, "山田", japanese.ShiftJIS) {
+		t.Errorf("body = %q; want it encoded in shift_jis", body)
+	}
+
+	// characters missing from the charset are sent as references
+	if _, err := r.Post(ts.URL, url.Values{"name": {"张三😀"}}); err != nil {
+		t.Fatal(err)
+	}
+	if body != "name="+url.QueryEscape(gbk("张三")+"&#128512;") {
+		t.Errorf("body = %s; want a character reference", body)
+	}
+
+	if _, err := r.Post(ts.URL, Param{"name": "张三"}, FileUpload{File: stringFile("hello"), FieldName: "media", FileName: "a.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if field != gbk("张三") {
+		t.Errorf("multipart field = %q; want it encoded in gbk", field)
+	}
+
+	if _, err := r.Get(ts.URL, RequestCharset("unknown")); err == nil {
+		t.Error("unknown charset accepted")
+	}
+}
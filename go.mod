@@ -3,6 +3,9 @@ module github.com/d1937/req
 go 1.16
 
 require (
-	github.com/axgle/mahonia v0.0.0-20180208002826-3358181d7394
-	golang.org/x/net v0.0.0-20210825183410-e898025ed96a
+	github.com/andybalholm/brotli v1.0.4
+	github.com/klauspost/compress v1.15.9
+	golang.org/x/net v0.0.0-20211112202133-69e39bad7dc2
+	golang.org/x/text v0.3.6
+	software.sslmate.com/src/go-pkcs12 v0.2.0
 )
@@ -0,0 +1,370 @@
+package req
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR is a HTTP Archive 1.2 document, which can be opened by the
+// devtools of browsers.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root of a HAR document.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator names the application which created the HAR document.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a request with its response.
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+// HARRequest is a request of a HAREntry.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARResponse is a response of a HAREntry.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARNameValue is a header or a query parameter.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARCookie is a cookie of a request or a response.
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+// HARPostData is the body of a request.
+type HARPostData struct {
+	MimeType string     `json:"mimeType"`
+	Params   []HARParam `json:"params,omitempty"`
+	Text     string     `json:"text"`
+}
+
+// HARParam is a urlencoded or multipart form field.
+type HARParam struct {
+	Name        string `json:"name"`
+	Value       string `json:"value,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// HARContent is the body of a response.
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings are the timings of a HAREntry in milliseconds,
+// -1 when they do not apply.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HARRecorder records requests and their responses as HAR entries.
+// It reads the whole response bodies, which stay available from Resp.
+type HARRecorder struct {
+	mu      sync.Mutex
+	entries []HAREntry
+}
+
+// NewHARRecorder creates a new *HARRecorder.
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{}
+}
+
+// RecordHAR makes rec record every request of r, including each
+// retried attempt. The secrets described by the Redaction are hidden.
+func (r *Req) RecordHAR(rec *HARRecorder) {
+	r.OnAfterResponse(func(resp *Resp) error {
+		rec.Record(resp)
+		return nil
+	})
+}
+
+// Record adds the request and response of resp.
+func (h *HARRecorder) Record(resp *Resp) {
+	entry := newHAREntry(resp)
+	h.mu.Lock()
+	h.entries = append(h.entries, entry)
+	h.mu.Unlock()
+}
+
+// HAR returns a HAR document of the recorded entries.
+func (h *HARRecorder) HAR() *HAR {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := make([]HAREntry, len(h.entries))
+	copy(entries, h.entries)
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "github.com/d1937/req", Version: "1.0"},
+		Entries: entries,
+	}}
+}
+
+// Export writes the HAR document of the recorded entries to w.
+func (h *HARRecorder) Export(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(h.HAR())
+}
+
+// Save writes the HAR document of the recorded entries to the file name.
+func (h *HARRecorder) Save(name string) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err = h.Export(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func newHAREntry(resp *Resp) HAREntry {
+	rd := resp.redaction
+	req := resp.req
+	if resp.resp.Request != nil {
+		// the request as sent, after redirects
+		req = resp.resp.Request
+	}
+	body, _ := resp.ToBytes()
+	info := resp.TraceInfo()
+
+	entry := HAREntry{
+		Time:     milliseconds(info.Total),
+		Request:  newHARRequest(resp, req),
+		Response: newHARResponse(resp, body),
+		Timings: HARTimings{
+			Blocked: -1,
+			DNS:     -1,
+			Connect: -1,
+			SSL:     -1,
+			Wait:    milliseconds(info.FirstByte - info.DNSLookup - info.TCPConnect - info.TLSHandshake),
+			Receive: milliseconds(info.BodyRead),
+		},
+	}
+	if resp.trace != nil {
+		entry.StartedDateTime = resp.trace.start.Format(time.RFC3339Nano)
+	}
+	if info.DNSLookup > 0 {
+		entry.Timings.DNS = milliseconds(info.DNSLookup)
+	}
+	if info.TCPConnect > 0 {
+		// the connect time includes the tls handshake
+		entry.Timings.Connect = milliseconds(info.TCPConnect + info.TLSHandshake)
+	}
+	if info.TLSHandshake > 0 {
+		entry.Timings.SSL = milliseconds(info.TLSHandshake)
+	}
+	entry.Request.URL = rd.redactURL(req.URL).String()
+	return entry
+}
+
+func harHeaders(h http.Header, rd *Redaction) []HARNameValue {
+	h = rd.redactHeader(h)
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	headers := []HARNameValue{}
+	for _, key := range keys {
+		for _, value := range h[key] {
+			headers = append(headers, HARNameValue{Name: key, Value: value})
+		}
+	}
+	return headers
+}
+
+func harCookies(cookies []*http.Cookie, masked bool, mask string) []HARCookie {
+	list := []HARCookie{}
+	for _, c := range cookies {
+		cookie := HARCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			cookie.Expires = c.Expires.Format(time.RFC3339)
+		}
+		if masked {
+			cookie.Value = mask
+		}
+		list = append(list, cookie)
+	}
+	return list
+}
+
+// harValues returns the pairs of an urlencoded string, in order.
+func harValues(s string) []HARNameValue {
+	values := []HARNameValue{}
+	for _, pair := range splitPairs(s) {
+		values = append(values, HARNameValue{Name: pair[0], Value: pair[1]})
+	}
+	return values
+}
+
+// splitPairs splits an urlencoded string into unescaped key value pairs,
+// keeping their order.
+func splitPairs(s string) [][2]string {
+	var pairs [][2]string
+	for _, pair := range strings.Split(s, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value := pair, ""
+		if i := strings.IndexByte(pair, '='); i >= 0 {
+			key, value = pair[:i], pair[i+1:]
+		}
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs
+}
+
+func newHARRequest(resp *Resp, req *http.Request) HARRequest {
+	rd := resp.redaction
+	hr := HARRequest{
+		Method:      req.Method,
+		HTTPVersion: req.Proto,
+		Cookies:     harCookies(req.Cookies(), rd != nil && containsFold(rd.Headers, "Cookie"), rd.mask()),
+		Headers:     harHeaders(req.Header, rd),
+		QueryString: harValues(rd.redactURL(req.URL).RawQuery),
+		HeadersSize: -1,
+		BodySize:    req.ContentLength,
+	}
+	contentType := req.Header.Get("Content-Type")
+	if m := resp.multipartHelper; m != nil {
+		hr.PostData = &HARPostData{
+			MimeType: contentType,
+			Text:     string(m.dump(rd.redactForm(m.form))),
+		}
+		for key, values := range rd.redactForm(m.form) {
+			for _, value := range values {
+				hr.PostData.Params = append(hr.PostData.Params, HARParam{Name: key, Value: value})
+			}
+		}
+		for _, up := range m.uploads {
+			hr.PostData.Params = append(hr.PostData.Params, HARParam{
+				Name:        up.FieldName,
+				FileName:    up.FileName,
+				ContentType: up.ContentType,
+			})
+		}
+	} else if body := resp.requestBody(); len(body) > 0 {
+		text := string(rd.redactBody(body, contentType))
+		hr.PostData = &HARPostData{MimeType: contentType, Text: text}
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/x-www-form-urlencoded" {
+			for _, p := range harValues(text) {
+				hr.PostData.Params = append(hr.PostData.Params, HARParam{Name: p.Name, Value: p.Value})
+			}
+		}
+	}
+	return hr
+}
+
+func newHARResponse(resp *Resp, body []byte) HARResponse {
+	rd := resp.redaction
+	response := resp.resp
+	hr := HARResponse{
+		Status:      response.StatusCode,
+		StatusText:  http.StatusText(response.StatusCode),
+		HTTPVersion: response.Proto,
+		Cookies:     harCookies(response.Cookies(), rd != nil && containsFold(rd.Headers, "Set-Cookie"), rd.mask()),
+		Headers:     harHeaders(response.Header, rd),
+		RedirectURL: response.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    response.ContentLength,
+		Content: HARContent{
+			Size:     int64(len(body)),
+			MimeType: response.Header.Get("Content-Type"),
+		},
+	}
+	if hr.BodySize < 0 {
+		hr.BodySize = int64(len(body))
+	}
+	body = rd.redactBody(body, hr.Content.MimeType)
+	if utf8.Valid(body) {
+		hr.Content.Text = string(body)
+	} else {
+		hr.Content.Text = base64.StdEncoding.EncodeToString(body)
+		hr.Content.Encoding = "base64"
+	}
+	return hr
+}
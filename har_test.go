@@ -0,0 +1,106 @@
+package req
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHARRecorder(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"code":1}`))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	rec := NewHARRecorder()
+	r := New()
+	r.SetRedaction(nil)
+	r.RecordHAR(rec)
+	resp, err := r.Post(ts.URL+"/post?page=1", Param{"name": "roc"}, &http.Cookie{Name: "id", Value: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != `{"code":1}` {
+		t.Errorf("response body = %s; want it still readable", resp.String())
+	}
+	upload := FileUpload{File: stringFile("hello req"), FieldName: "media", FileName: "hello.txt"}
+	if _, err = r.Post(ts.URL+"/upload", upload); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = rec.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var har HAR
+	if err = json.Unmarshal(buf.Bytes(), &har); err != nil {
+		t.Fatal(err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 2 {
+		t.Fatalf("version = %s, entries = %d; want = 1.2, 2", har.Log.Version, len(har.Log.Entries))
+	}
+
+	entry := har.Log.Entries[0]
+	hr := entry.Request
+	if hr.Method != "POST" || hr.URL != ts.URL+"/post?page=1" {
+		t.Errorf("request = %s %s; want = POST %s/post?page=1", hr.Method, hr.URL, ts.URL)
+	}
+	if len(hr.QueryString) != 1 || hr.QueryString[0] != (HARNameValue{"page", "1"}) {
+		t.Errorf("query string = %+v; want page=1", hr.QueryString)
+	}
+	if len(hr.Cookies) != 1 || hr.Cookies[0].Name != "id" {
+		t.Errorf("request cookies = %+v; want id=1", hr.Cookies)
+	}
+	if hr.PostData == nil || hr.PostData.Text != "name=roc" || len(hr.PostData.Params) != 1 {
+		t.Errorf("post data = %+v; want name=roc", hr.PostData)
+	}
+	if entry.Response.Status != 200 || entry.Response.Content.Text != `{"code":1}` {
+		t.Errorf("response = %d %s; want = 200 {\"code\":1}", entry.Response.Status, entry.Response.Content.Text)
+	}
+	if len(entry.Response.Cookies) != 1 || entry.Response.Cookies[0].Value != "abc" {
+		t.Errorf("response cookies = %+v; want session=abc", entry.Response.Cookies)
+	}
+	if entry.StartedDateTime == "" || entry.Time <= 0 {
+		t.Errorf("started = %s, time = %v; want them set", entry.StartedDateTime, entry.Time)
+	}
+
+	post := har.Log.Entries[1].Request.PostData
+	if post == nil || !strings.Contains(post.Text, "hello req") || len(post.Params) != 1 || post.Params[0].FileName != "hello.txt" {
+		t.Errorf("multipart post data = %+v; want the file hello.txt", post)
+	}
+}
+
+func TestHARRedaction(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	rec := NewHARRecorder()
+	r := New()
+	r.RecordHAR(rec)
+	if _, err := r.Get(ts.URL, Header{"Authorization": "secret"}, &http.Cookie{Name: "id", Value: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	_ = rec.Export(&buf)
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("HAR contains secrets: %s", buf.String())
+	}
+}
+
+func stringFile(s string) *nopCloser {
+	return &nopCloser{strings.NewReader(s)}
+}
+
+type nopCloser struct {
+	*strings.Reader
+}
+
+func (nopCloser) Close() error { return nil }
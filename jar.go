@@ -0,0 +1,474 @@
+package req
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieFormat is the file format of a Jar.
+type CookieFormat int
+
+const (
+	// CookieJSON is a JSON array of cookies.
+	CookieJSON CookieFormat = iota
+	// CookieNetscape is the cookies.txt format of Netscape, used by curl
+	// and wget.
+	CookieNetscape
+)
+
+// JarOptions are the options of NewJar.
+type JarOptions struct {
+	// PublicSuffixList rejects cookies set for a public suffix like
+	// "co.uk", defaults to golang.org/x/net/publicsuffix.
+	PublicSuffixList cookiejar.PublicSuffixList
+	// Filename is the file the cookies are loaded from by NewJar, if
+	// it exists, and saved to by Save.
+	Filename string
+	// Format is the format of Filename.
+	Format CookieFormat
+	// AutoSave saves the cookies to Filename whenever they change, the
+	// errors are ignored.
+	AutoSave bool
+}
+
+// Jar is a http.CookieJar which can list its cookies and save them to
+// a file, session cookies included.
+type Jar struct {
+	psList   cookiejar.PublicSuffixList
+	filename string
+	format   CookieFormat
+	autoSave bool
+
+	mu      sync.Mutex
+	entries map[string]*jarEntry
+	seq     uint64
+	// saveMu serializes the writes of the file
+	saveMu sync.Mutex
+}
+
+type jarEntry struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Domain   string     `json:"domain"`
+	Path     string     `json:"path"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+	HttpOnly bool       `json:"httpOnly,omitempty"`
+	HostOnly bool       `json:"hostOnly,omitempty"`
+	Creation time.Time  `json:"creation"`
+	// seq orders the cookies created at the same time
+	seq uint64
+}
+
+func (e *jarEntry) id() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+func (e *jarEntry) expired(now time.Time) bool {
+	return e.Expires != nil && !e.Expires.After(now)
+}
+
+func (e *jarEntry) domainMatch(host string) bool {
+	return e.Domain == host || !e.HostOnly && strings.HasSuffix(host, "."+e.Domain)
+}
+
+func (e *jarEntry) pathMatch(path string) bool {
+	if path == e.Path {
+		return true
+	}
+	return strings.HasPrefix(path, e.Path) && (strings.HasSuffix(e.Path, "/") || path[len(e.Path)] == '/')
+}
+
+func (e *jarEntry) cookie() *http.Cookie {
+	c := &http.Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Domain:   e.Domain,
+		Path:     e.Path,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+	}
+	if e.Expires != nil {
+		c.Expires = *e.Expires
+	}
+	return c
+}
+
+// NewJar creates a new *Jar, loading the cookies of o.Filename.
+// o may be nil.
+func NewJar(o *JarOptions) (*Jar, error) {
+	if o == nil {
+		o = &JarOptions{}
+	}
+	j := &Jar{
+		psList:   o.PublicSuffixList,
+		filename: o.Filename,
+		format:   o.Format,
+		autoSave: o.AutoSave && o.Filename != "",
+		entries:  make(map[string]*jarEntry),
+	}
+	if j.psList == nil {
+		j.psList = publicsuffix.List
+	}
+	if j.filename == "" {
+		return j, nil
+	}
+	file, err := os.Open(j.filename)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err = j.Import(file, j.format); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// canonicalHost returns the lower case host of u, without port.
+func canonicalHost(u *url.URL) string {
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// Cookies implements http.CookieJar.
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	host := canonicalHost(u)
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	secure := u.Scheme == "https"
+	now := time.Now()
+
+	j.mu.Lock()
+	var selected []*jarEntry
+	for id, e := range j.entries {
+		if e.expired(now) {
+			delete(j.entries, id)
+			continue
+		}
+		if (!e.Secure || secure) && e.domainMatch(host) && e.pathMatch(path) {
+			selected = append(selected, e)
+		}
+	}
+	j.mu.Unlock()
+
+	// longer paths first, then older cookies first, see RFC 6265 5.4
+	sort.Slice(selected, func(a, b int) bool {
+		if len(selected[a].Path) != len(selected[b].Path) {
+			return len(selected[a].Path) > len(selected[b].Path)
+		}
+		if !selected[a].Creation.Equal(selected[b].Creation) {
+			return selected[a].Creation.Before(selected[b].Creation)
+		}
+		return selected[a].seq < selected[b].seq
+	})
+	cookies := make([]*http.Cookie, len(selected))
+	for i, e := range selected {
+		cookies[i] = &http.Cookie{Name: e.Name, Value: e.Value}
+	}
+	return cookies
+}
+
+// SetCookies implements http.CookieJar.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	host := canonicalHost(u)
+	now := time.Now()
+	changed := false
+
+	j.mu.Lock()
+	for _, c := range cookies {
+		e, ok := j.newEntry(c, host, u.Path, now)
+		if !ok {
+			continue
+		}
+		id := e.id()
+		old := j.entries[id]
+		if e.expired(now) {
+			if old != nil {
+				delete(j.entries, id)
+				changed = true
+			}
+			continue
+		}
+		if old != nil {
+			e.Creation, e.seq = old.Creation, old.seq
+		} else {
+			j.seq++
+			e.seq = j.seq
+		}
+		j.entries[id] = e
+		changed = true
+	}
+	j.mu.Unlock()
+
+	if changed && j.autoSave {
+		_ = j.Save()
+	}
+}
+
+// newEntry validates c received from host like net/http/cookiejar does.
+func (j *Jar) newEntry(c *http.Cookie, host, requestPath string, now time.Time) (*jarEntry, bool) {
+	e := &jarEntry{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		Creation: now,
+	}
+	if e.Path == "" || e.Path[0] != '/' {
+		// the directory of the request path
+		e.Path = "/"
+		if i := strings.LastIndex(requestPath, "/"); i > 0 {
+			e.Path = requestPath[:i]
+		}
+	}
+
+	domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+	switch {
+	case c.Domain == "":
+		e.Domain, e.HostOnly = host, true
+	case net.ParseIP(host) != nil:
+		if domain != host {
+			return nil, false
+		}
+		e.Domain, e.HostOnly = host, true
+	case domain == "" || domain[0] == '.' || strings.HasSuffix(domain, "."):
+		return nil, false
+	default:
+		if ps := j.psList.PublicSuffix(domain); ps != "" && !strings.HasSuffix(domain, "."+ps) {
+			// a cookie for a public suffix is only valid for that host
+			if host != domain {
+				return nil, false
+			}
+			e.Domain, e.HostOnly = host, true
+			break
+		}
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			return nil, false
+		}
+		e.Domain = domain
+	}
+
+	switch {
+	case c.MaxAge < 0:
+		e.Expires = &time.Time{}
+	case c.MaxAge > 0:
+		expires := now.Add(time.Duration(c.MaxAge) * time.Second)
+		e.Expires = &expires
+	case !c.Expires.IsZero():
+		expires := c.Expires
+		e.Expires = &expires
+	}
+	return e, true
+}
+
+// AllCookies returns all the cookies which have not expired, with their
+// domain, path and expiry. The Expires of session cookies is zero.
+func (j *Jar) AllCookies() []*http.Cookie {
+	entries := j.snapshot()
+	cookies := make([]*http.Cookie, len(entries))
+	for i, e := range entries {
+		cookies[i] = e.cookie()
+	}
+	return cookies
+}
+
+// snapshot returns copies of the entries which have not expired,
+// sorted by domain, path and name.
+func (j *Jar) snapshot() []jarEntry {
+	now := time.Now()
+	j.mu.Lock()
+	entries := make([]jarEntry, 0, len(j.entries))
+	for id, e := range j.entries {
+		if e.expired(now) {
+			delete(j.entries, id)
+			continue
+		}
+		entries = append(entries, *e)
+	}
+	j.mu.Unlock()
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].id() < entries[b].id()
+	})
+	return entries
+}
+
+// Clear removes all the cookies.
+func (j *Jar) Clear() {
+	j.mu.Lock()
+	j.entries = make(map[string]*jarEntry)
+	j.mu.Unlock()
+	if j.autoSave {
+		_ = j.Save()
+	}
+}
+
+// Export writes the cookies to w in the given format.
+func (j *Jar) Export(w io.Writer, format CookieFormat) error {
+	entries := j.snapshot()
+	switch format {
+	case CookieJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case CookieNetscape:
+		bw := bufio.NewWriter(w)
+		_, _ = bw.WriteString("# Netscape HTTP Cookie File\n\n")
+		for _, e := range entries {
+			domain := e.Domain
+			if !e.HostOnly {
+				domain = "." + domain
+			}
+			if e.HttpOnly {
+				domain = "#HttpOnly_" + domain
+			}
+			var expires int64
+			if e.Expires != nil {
+				expires = e.Expires.Unix()
+			}
+			fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, netscapeBool(!e.HostOnly),
+				e.Path, netscapeBool(e.Secure), expires, e.Name, e.Value)
+		}
+		return bw.Flush()
+	}
+	return fmt.Errorf("req: unknown cookie format %d", format)
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// Import adds the cookies read from rd in the given format, replacing
+// the cookies with the same domain, path and name.
+func (j *Jar) Import(rd io.Reader, format CookieFormat) error {
+	var entries []*jarEntry
+	switch format {
+	case CookieJSON:
+		if err := json.NewDecoder(rd).Decode(&entries); err != nil {
+			return err
+		}
+	case CookieNetscape:
+		var err error
+		if entries, err = parseNetscapeCookies(rd); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("req: unknown cookie format %d", format)
+	}
+
+	now := time.Now()
+	j.mu.Lock()
+	for _, e := range entries {
+		if e.Name == "" || e.Domain == "" || e.expired(now) {
+			continue
+		}
+		e.Domain = strings.ToLower(e.Domain)
+		if e.Path == "" {
+			e.Path = "/"
+		}
+		if e.Creation.IsZero() {
+			e.Creation = now
+		}
+		j.seq++
+		e.seq = j.seq
+		j.entries[e.id()] = e
+	}
+	j.mu.Unlock()
+	return nil
+}
+
+func parseNetscapeCookies(rd io.Reader) ([]*jarEntry, error) {
+	var entries []*jarEntry
+	scanner := bufio.NewScanner(rd)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		if httpOnly {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// the value of the cookie is empty
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("req: invalid cookies.txt line %d", n)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("req: invalid cookies.txt line %d: %v", n, err)
+		}
+		e := &jarEntry{
+			Domain:   strings.TrimPrefix(fields[0], "."),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			t := time.Unix(expires, 0)
+			e.Expires = &t
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Save writes the cookies to the file of the JarOptions, replacing it
+// atomically.
+func (j *Jar) Save() error {
+	if j.filename == "" {
+		return errors.New("req: jar has no file name")
+	}
+	j.saveMu.Lock()
+	defer j.saveMu.Unlock()
+	file, err := ioutil.TempFile(filepath.Dir(j.filename), filepath.Base(j.filename)+".*")
+	if err != nil {
+		return err
+	}
+	if err = j.Export(file, j.format); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err = file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), j.filename)
+}
@@ -0,0 +1,162 @@
+package req
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func cookieNames(cookies []*http.Cookie) string {
+	var names []string
+	for _, c := range cookies {
+		names = append(names, c.Name+"="+c.Value)
+	}
+	return strings.Join(names, "; ")
+}
+
+func TestJar(t *testing.T) {
+	jar, err := NewJar(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("https://www.example.com/a/b")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".example.com", Path: "/"},
+		{Name: "path", Value: "3", Path: "/a/b"},
+		{Name: "secure", Value: "4", Secure: true, Path: "/"},
+		{Name: "expired", Value: "5", MaxAge: -1},
+		{Name: "suffix", Value: "6", Domain: "com"},
+		{Name: "other", Value: "7", Domain: "other.com"},
+	})
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.example.com/a/b", "path=3; host=1; domain=2; secure=4"},
+		{"https://www.example.com/a", "host=1; domain=2; secure=4"},
+		{"http://www.example.com/", "domain=2"},
+		{"https://sub.example.com/a", "domain=2"},
+		{"https://example.com/a", "domain=2"},
+		{"https://www.example.org/", ""},
+	}
+	for _, test := range tests {
+		u, _ := url.Parse(test.url)
+		if got := cookieNames(jar.Cookies(u)); got != test.want {
+			t.Errorf("cookies of %s = %q; want = %q", test.url, got, test.want)
+		}
+	}
+	if n := len(jar.AllCookies()); n != 4 {
+		t.Errorf("all cookies = %d; want = 4", n)
+	}
+
+	// replace and delete
+	jar.SetCookies(u, []*http.Cookie{{Name: "host", Value: "8"}, {Name: "domain", Domain: "example.com", Path: "/", MaxAge: -1}})
+	if got := cookieNames(jar.Cookies(u)); got != "path=3; host=8; secure=4" {
+		t.Errorf("cookies = %q; want the domain cookie deleted", got)
+	}
+	jar.Clear()
+	if n := len(jar.AllCookies()); n != 0 {
+		t.Errorf("all cookies = %d; want none after Clear", n)
+	}
+}
+
+func TestJarExport(t *testing.T) {
+	u, _ := url.Parse("https://www.example.com/")
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	for _, format := range []CookieFormat{CookieJSON, CookieNetscape} {
+		jar, _ := NewJar(nil)
+		jar.SetCookies(u, []*http.Cookie{
+			{Name: "session", Value: "abc", HttpOnly: true},
+			{Name: "id", Value: "1", Domain: "example.com", Path: "/", Secure: true, Expires: expires},
+		})
+		var buf bytes.Buffer
+		if err := jar.Export(&buf, format); err != nil {
+			t.Fatal(err)
+		}
+		loaded, _ := NewJar(nil)
+		if err := loaded.Import(&buf, format); err != nil {
+			t.Fatal(err)
+		}
+		got, want := loaded.AllCookies(), jar.AllCookies()
+		if len(got) != len(want) {
+			t.Fatalf("format %d: imported %d cookies; want = %d", format, len(got), len(want))
+		}
+		for i := range got {
+			if got[i].String() != want[i].String() || !got[i].Expires.Equal(want[i].Expires) {
+				t.Errorf("format %d: imported cookie = %v; want = %v", format, got[i], want[i])
+			}
+		}
+		if cookies := cookieNames(loaded.Cookies(u)); cookies != "session=abc; id=1" && cookies != "id=1; session=abc" {
+			t.Errorf("format %d: cookies = %q; want both", format, cookies)
+		}
+	}
+
+	netscape := "# Netscape HTTP Cookie File\n" +
+		".example.com\tTRUE\t/\tFALSE\t0\tsid\txyz\n" +
+		"#HttpOnly_www.example.com\tFALSE\t/\tTRUE\t1\texpired\t1\n"
+	jar, _ := NewJar(nil)
+	if err := jar.Import(strings.NewReader(netscape), CookieNetscape); err != nil {
+		t.Fatal(err)
+	}
+	if got := cookieNames(jar.AllCookies()); got != "sid=xyz" {
+		t.Errorf("cookies = %q; want = sid=xyz", got)
+	}
+	if err := jar.Import(strings.NewReader("invalid line\n"), CookieNetscape); err == nil {
+		t.Error("invalid cookies.txt imported")
+	}
+}
+
+func TestJarAutoSave(t *testing.T) {
+	var cookie string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie = r.Header.Get("Cookie")
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		}
+	}))
+	defer ts.Close()
+
+	name := filepath.Join(t.TempDir(), "cookies.txt")
+	jar, err := NewJar(&JarOptions{Filename: name, Format: CookieNetscape, AutoSave: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.EnableCookie(true, jar)
+	if r.CookieJar() != jar {
+		t.Error("jar not in use")
+	}
+	if _, err = r.Get(ts.URL + "/login"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted program logs in with the saved cookies
+	jar, err = NewJar(&JarOptions{Filename: name, Format: CookieNetscape})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = New()
+	r.EnableCookie(true, jar)
+	if _, err = r.Get(ts.URL + "/home"); err != nil {
+		t.Fatal(err)
+	}
+	if cookie != "session=abc" {
+		t.Errorf("cookie = %q; want = session=abc", cookie)
+	}
+
+	r.EnableCookie(true)
+	if r.CookieJar() == nil || r.CookieJar() == jar {
+		t.Error("EnableCookie(true) did not create an in-memory jar")
+	}
+	r.EnableCookie(false)
+	if r.CookieJar() != nil {
+		t.Error("cookies not disabled")
+	}
+}
@@ -0,0 +1,82 @@
+package req
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Logger receives the debug output of requests, *log.Logger implements it.
+type Logger interface {
+	Println(v ...interface{})
+}
+
+// NewLogger returns a Logger writing every entry to w.
+func NewLogger(w io.Writer) Logger {
+	return &writerLogger{w: w}
+}
+
+type writerLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *writerLogger) Println(v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = fmt.Fprintln(l.w, v...)
+}
+
+var defaultLogger = NewLogger(os.Stdout)
+
+// DumpFlags overrides the debug output of one request: passed to Do, the
+// request is dumped with the given flags, DumpFlags(0) disables the output.
+type DumpFlags int
+
+// SetDebug enables or disables the debug output of every request.
+func (r *Req) SetDebug(enable bool) {
+	r.mu.Lock()
+	r.debug = enable
+	r.mu.Unlock()
+}
+
+// SetLogger sets the Logger receiving the debug output,
+// nil restores the default one writing to os.Stdout.
+func (r *Req) SetLogger(logger Logger) {
+	r.mu.Lock()
+	r.logger = logger
+	r.mu.Unlock()
+}
+
+// SetFlags sets the output flags of Resp.Dump and the debug output,
+// a combination of LreqHead, LreqBody, LrespHead, LrespBody and Lcost.
+func (r *Req) SetFlags(flag int) {
+	r.mu.Lock()
+	r.flag = flag
+	r.mu.Unlock()
+}
+
+// Flags returns the output flags of Resp.Dump and the debug output.
+func (r *Req) Flags() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.flag
+}
+
+func (r *Req) getLogger() Logger {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.logger == nil {
+		return defaultLogger
+	}
+	return r.logger
+}
+
+// debugEnabled reports whether requests of r are dumped, either
+// with SetDebug or with the package level Debug.
+func (r *Req) debugEnabled() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.debug || Debug
+}
@@ -0,0 +1,63 @@
+package req
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugLogger(t *testing.T) {
+	debug := Debug
+	Debug = false
+	defer func() { Debug = debug }()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Response-Header", "req")
+		_, _ = w.Write([]byte("response body"))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	r := New()
+	r.SetLogger(NewLogger(&buf))
+
+	if _, err := r.Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("output = %q; want none when debug is disabled", buf.String())
+	}
+
+	r.SetDebug(true)
+	r.SetFlags(LreqHead)
+	if r.Flags() != LreqHead {
+		t.Errorf("flags = %d; want = %d", r.Flags(), LreqHead)
+	}
+	if _, err := r.Get(ts.URL + "/head"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "GET /head HTTP/1.1") || strings.Contains(out, "Response-Header") {
+		t.Errorf("output = %q; want the request head only", out)
+	}
+
+	// per request overrides
+	buf.Reset()
+	if _, err := r.Get(ts.URL, DumpFlags(0)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("output = %q; want none with DumpFlags(0)", buf.String())
+	}
+	r.SetDebug(false)
+	if _, err := r.Get(ts.URL, DumpFlags(LrespHead|LrespBody)); err != nil {
+		t.Fatal(err)
+	}
+	out = buf.String()
+	if !strings.Contains(out, "Response-Header: req") || !strings.Contains(out, "response body") || strings.Contains(out, "GET /") {
+		t.Errorf("output = %q; want the response only", out)
+	}
+}
@@ -0,0 +1,92 @@
+package req
+
+import (
+	"net/http"
+)
+
+// Handler sends a request and returns its response.
+type Handler func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps a Handler, it may inspect or modify the request
+// before calling next, and the response after.
+type Middleware func(next Handler) Handler
+
+// RequestHook is called before each attempt of a request is sent,
+// returning an error aborts the request.
+type RequestHook func(req *http.Request) error
+
+// ResponseHook is called after each attempt of a request got a response,
+// returning an error makes Do return it.
+type ResponseHook func(resp *Resp) error
+
+// Use appends middlewares wrapping every request, the first one
+// is the outermost.
+func (r *Req) Use(middlewares ...Middleware) {
+	r.mu.Lock()
+	r.middlewares = append(r.middlewares, middlewares...)
+	r.mu.Unlock()
+}
+
+// OnBeforeRequest appends a hook called before every request is sent.
+func (r *Req) OnBeforeRequest(hook RequestHook) {
+	r.mu.Lock()
+	r.beforeHooks = append(r.beforeHooks, hook)
+	r.mu.Unlock()
+}
+
+// OnAfterResponse appends a hook called after every response is received.
+func (r *Req) OnAfterResponse(hook ResponseHook) {
+	r.mu.Lock()
+	r.afterHooks = append(r.afterHooks, hook)
+	r.mu.Unlock()
+}
+
+// chain wraps client.Do with the middlewares of r.
+func (r *Req) chain(client *http.Client) Handler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h := Handler(client.Do)
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		h = r.middlewares[i](h)
+	}
+	return h
+}
+
+func (r *Req) hooks() ([]RequestHook, []ResponseHook) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.beforeHooks, r.afterHooks
+}
+
+// hookError wraps an error returned by a hook, which is never retried.
+type hookError struct {
+	err error
+}
+
+func (e *hookError) Error() string { return e.err.Error() }
+
+// roundTrip sends one attempt of the request through the hooks
+// and middlewares of r.
+func (r *Req) roundTrip(resp *Resp, req *http.Request) (*http.Response, error) {
+	before, after := r.hooks()
+	for _, hook := range before {
+		if err := hook(req); err != nil {
+			return nil, &hookError{err}
+		}
+	}
+	t := newTracer()
+	resp.trace = t
+	response, err := r.chain(resp.client)(t.trace(req))
+	resp.resp = response
+	if err != nil {
+		return response, err
+	}
+	t.traceResponse(response)
+	for _, hook := range after {
+		if err := hook(resp); err != nil {
+			_ = response.Body.Close()
+			return response, &hookError{err}
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,110 @@
+package req
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("X-Sign")))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+" before")
+				resp, err := next(req)
+				order = append(order, name+" after")
+				return resp, err
+			}
+		}
+	}
+	sign := func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Sign", req.Method+" "+req.URL.Path)
+			return next(req)
+		}
+	}
+
+	r := New()
+	r.Use(trace("outer"), trace("inner"), sign)
+	r.OnBeforeRequest(func(req *http.Request) error {
+		order = append(order, "before request")
+		return nil
+	})
+	r.OnAfterResponse(func(resp *Resp) error {
+		order = append(order, "after response")
+		return nil
+	})
+	resp, err := r.Get(ts.URL + "/sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != "GET /sign" {
+		t.Errorf("response body = %s; want = GET /sign", resp.String())
+	}
+	want := "before request,outer before,inner before,inner after,outer after,after response"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("order = %s; want = %s", got, want)
+	}
+}
+
+func TestHookError(t *testing.T) {
+	ts, count := newFlakyServer(t, 0, 0, "")
+	defer ts.Close()
+	errHook := errors.New("hook error")
+
+	r := New()
+	policy := NewRetryPolicy(3)
+	policy.Backoff = func(n int) time.Duration { return 0 }
+	r.SetRetry(policy)
+	r.OnBeforeRequest(func(req *http.Request) error {
+		return errHook
+	})
+	if _, err := r.Get(ts.URL); err != errHook {
+		t.Errorf("error = %v; want = %v", err, errHook)
+	}
+	if *count != 0 {
+		t.Errorf("requests = %d; want = 0", *count)
+	}
+
+	r = New()
+	r.SetRetry(policy)
+	r.OnAfterResponse(func(resp *Resp) error {
+		return errHook
+	})
+	if _, err := r.Get(ts.URL); err != errHook {
+		t.Errorf("error = %v; want = %v", err, errHook)
+	}
+	if *count != 1 {
+		t.Errorf("requests = %d; want = 1", *count)
+	}
+}
+
+func TestHooksSeeEveryAttempt(t *testing.T) {
+	ts, _ := newFlakyServer(t, 2, http.StatusBadGateway, "")
+	defer ts.Close()
+
+	var statuses []int
+	r := New()
+	r.OnAfterResponse(func(resp *Resp) error {
+		statuses = append(statuses, resp.Response().StatusCode)
+		return nil
+	})
+	policy := NewRetryPolicy(3)
+	policy.Backoff = func(n int) time.Duration { return 0 }
+	if _, err := r.Get(ts.URL, policy); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 || statuses[0] != http.StatusBadGateway || statuses[2] != http.StatusOK {
+		t.Errorf("statuses = %v; want = [502 502 200]", statuses)
+	}
+}
@@ -0,0 +1,227 @@
+package req
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// PublicKeyPin returns the pin of the public key of cert, the base64
+// encoded SHA-256 of its SubjectPublicKeyInfo prefixed with "sha256/".
+func PublicKeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// CertificatePin returns the pin of cert, the base64 encoded SHA-256 of
+// its DER encoding prefixed with "sha256/".
+func CertificatePin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// PinMismatchError is returned when the certificates of a pinned host do
+// not match any of its pins.
+type PinMismatchError struct {
+	Host string
+	// PublicKeyPins and CertificatePins are the pins of the certificates
+	// presented by the server, or of the verified chains when the server
+	// certificates are verified.
+	PublicKeyPins   []string
+	CertificatePins []string
+}
+
+func (e *PinMismatchError) Error() string {
+	return fmt.Sprintf("req: certificate of %s does not match its pins, public key pins: %s",
+		e.Host, strings.Join(e.PublicKeyPins, ", "))
+}
+
+type hostPins struct {
+	publicKeys   []string
+	certificates []string
+}
+
+// pinner checks the certificates of the pinned hosts, it is shared by the
+// TLS config of the transport so the pins can change while in use.
+type pinner struct {
+	mu    sync.RWMutex
+	hosts map[string]*hostPins
+	// config is the TLS config of the current transport, the configs of
+	// the previous ones no longer verify the pins
+	config *tls.Config
+}
+
+// pinHost returns the host name of host, which may have a port.
+func pinHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+func normalizePins(pins []string) ([]string, error) {
+	normalized := make([]string, 0, len(pins))
+	for _, pin := range pins {
+		hash := strings.TrimPrefix(pin, "sha256/")
+		if b, err := base64.StdEncoding.DecodeString(hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("req: invalid pin %q", pin)
+		}
+		normalized = append(normalized, "sha256/"+hash)
+	}
+	return normalized, nil
+}
+
+func (p *pinner) set(host string, set func(pins *hostPins)) {
+	host = pinHost(host)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pins := p.hosts[host]
+	if pins == nil {
+		pins = &hostPins{}
+	}
+	set(pins)
+	if len(pins.publicKeys) == 0 && len(pins.certificates) == 0 {
+		delete(p.hosts, host)
+		return
+	}
+	p.hosts[host] = pins
+}
+
+// verifyConnection checks the pins of the server, the certificates of the
+// verified chains may match, or only the leaf certificate if the chain is
+// not verified.
+func (p *pinner) verifyConnection(config *tls.Config, cs tls.ConnectionState) error {
+	host := pinHost(cs.ServerName)
+	p.mu.RLock()
+	pins := p.hosts[host]
+	current := p.config == config
+	p.mu.RUnlock()
+	if pins == nil || !current {
+		return nil
+	}
+	var certs []*x509.Certificate
+	if len(cs.VerifiedChains) > 0 {
+		for _, chain := range cs.VerifiedChains {
+			certs = append(certs, chain...)
+		}
+	} else if len(cs.PeerCertificates) > 0 {
+		certs = cs.PeerCertificates[:1]
+	}
+	e := &PinMismatchError{Host: host}
+	for _, cert := range certs {
+		keyPin, certPin := PublicKeyPin(cert), CertificatePin(cert)
+		if containsPin(pins.publicKeys, keyPin) || containsPin(pins.certificates, certPin) {
+			return nil
+		}
+		e.PublicKeyPins = append(e.PublicKeyPins, keyPin)
+		e.CertificatePins = append(e.CertificatePins, certPin)
+	}
+	return e
+}
+
+func containsPin(pins []string, pin string) bool {
+	for _, p := range pins {
+		if p == pin {
+			return true
+		}
+	}
+	return false
+}
+
+// install verifies the pins on the TLS config of the transport of r, the
+// idle connections are closed so the next requests check them
+func (p *pinner) install(r *Req) error {
+	err := r.setTLSConfig(func(config *tls.Config) error {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.config == config {
+			return nil
+		}
+		p.config = config
+		next := config.VerifyConnection
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			if next != nil {
+				if err := next(cs); err != nil {
+					return err
+				}
+			}
+			return p.verifyConnection(config, cs)
+		}
+		return nil
+	})
+	if err != nil {
+		// not an *http.Transport, the previous one is not used anymore
+		p.mu.Lock()
+		p.config = nil
+		p.mu.Unlock()
+	}
+	return err
+}
+
+// reinstallPins verifies the pins on the transport of r after it changed
+func (r *Req) reinstallPins() {
+	r.mu.RLock()
+	p := r.pins
+	r.mu.RUnlock()
+	if p != nil {
+		_ = p.install(r)
+	}
+}
+
+// setPins calls set with the pins of host and verifies the pins on the
+// TLS config of the transport
+func (r *Req) setPins(host string, set func(pins *hostPins)) error {
+	if net.ParseIP(pinHost(host)) != nil {
+		// the server name of the connection is empty for IP addresses
+		return fmt.Errorf("req: can not pin IP address %s", host)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pins == nil {
+		r.pins = &pinner{hosts: make(map[string]*hostPins)}
+	}
+	r.pins.set(host, set)
+	return r.pins.install(r)
+}
+
+// SetPublicKeyPins pins the public keys of host, a host name without
+// port, to pins like the ones returned by PublicKeyPin, the extra pins are
+// backups, like the key of the next certificate. The connections to host
+// fail with a *PinMismatchError unless a certificate of the server
+// matches a pin, even if the server certificates are not verified. When
+// they are verified, the pin may be of an intermediate or root CA,
+// otherwise it must be of the server certificate. No pins removes the
+// public key pins of host.
+//
+// host is matched against the server name, see SetServerName, IP
+// addresses can not be pinned since they are not sent by SNI. The pins
+// are checked by the *http.Transport of the Req, moved to the new one by
+// SetTransport and SetClient, but not by an *http.Client given to a
+// single request.
+func (r *Req) SetPublicKeyPins(host string, pins ...string) error {
+	pins, err := normalizePins(pins)
+	if err != nil {
+		return err
+	}
+	return r.setPins(host, func(hp *hostPins) {
+		hp.publicKeys = pins
+	})
+}
+
+// SetCertificatePins pins the certificates of host to pins like the ones
+// returned by CertificatePin, see SetPublicKeyPins. A host with both kinds
+// of pins only needs to match one pin.
+func (r *Req) SetCertificatePins(host string, pins ...string) error {
+	pins, err := normalizePins(pins)
+	if err != nil {
+		return err
+	}
+	return r.setPins(host, func(hp *hostPins) {
+		hp.certificates = pins
+	})
+}
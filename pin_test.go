@@ -0,0 +1,127 @@
+package req
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPins(t *testing.T) {
+	ca := newTestCert(t, nil)
+	leaf := newTestCert(t, ca, "localhost")
+	ts := newTLSServer(leaf, nil)
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	url := "https://localhost:" + port
+	other := newTestCert(t, nil)
+
+	mismatch := func(r *Req) {
+		t.Helper()
+		_, err := r.Get(url)
+		var pinErr *PinMismatchError
+		if !errors.As(err, &pinErr) {
+			t.Fatalf("err = %v; want = *PinMismatchError", err)
+		}
+		if pinErr.Host != "localhost" {
+			t.Errorf("host = %s; want = %s", pinErr.Host, "localhost")
+		}
+		if len(pinErr.PublicKeyPins) == 0 || pinErr.PublicKeyPins[0] != PublicKeyPin(leaf.cert) {
+			t.Errorf("public key pins = %v; want = %s first", pinErr.PublicKeyPins, PublicKeyPin(leaf.cert))
+		}
+	}
+	match := func(r *Req) {
+		t.Helper()
+		if _, err := r.Get(url); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// enforced without verification
+	r := New()
+	match(r)
+	if err := r.SetPublicKeyPins("localhost", PublicKeyPin(other.cert)); err != nil {
+		t.Fatal(err)
+	}
+	mismatch(r)
+	// backup pins
+	if err := r.SetPublicKeyPins("localhost:443", PublicKeyPin(other.cert), PublicKeyPin(leaf.cert)); err != nil {
+		t.Fatal(err)
+	}
+	match(r)
+	// only the leaf counts if the chain is not verified
+	r.SetPublicKeyPins("localhost", PublicKeyPin(ca.cert))
+	mismatch(r)
+
+	// any certificate of the verified chain
+	r.EnableInsecureTLS(false)
+	if err := r.AddRootCA(ca.certPEM); err != nil {
+		t.Fatal(err)
+	}
+	match(r)
+
+	// certificate pins
+	r.SetPublicKeyPins("localhost")
+	r.SetCertificatePins("LOCALHOST", CertificatePin(other.cert))
+	mismatch(r)
+	r.SetCertificatePins("localhost", CertificatePin(leaf.cert))
+	match(r)
+	r.SetCertificatePins("localhost")
+	r.SetPublicKeyPins("example.com", PublicKeyPin(other.cert))
+	match(r)
+
+	// not retried
+	r = New()
+	policy := NewRetryPolicy(3)
+	policy.Backoff = func(n int) time.Duration { return 0 }
+	retries := 0
+	policy.OnRetry = func(resp *Resp, attempt Attempt) { retries++ }
+	r.SetPublicKeyPins("localhost", PublicKeyPin(other.cert))
+	if _, err := r.Get(url, policy); err == nil || retries != 0 {
+		t.Errorf("err = %v, retries = %d; want = *PinMismatchError, 0", err, retries)
+	}
+
+	if err := r.SetPublicKeyPins("127.0.0.1", PublicKeyPin(other.cert)); err == nil {
+		t.Error("no error with an IP address")
+	}
+	if err := r.SetPublicKeyPins("localhost", "sha256/invalid"); err == nil {
+		t.Error("no error with an invalid pin")
+	}
+}
+
+func TestPinsTransportChange(t *testing.T) {
+	leaf := newTestCert(t, nil, "localhost")
+	ts := newTLSServer(leaf, nil)
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	url := "https://localhost:" + port
+	other := newTestCert(t, nil)
+
+	mismatch := func(r *Req) {
+		t.Helper()
+		var pinErr *PinMismatchError
+		if _, err := r.Get(url); !errors.As(err, &pinErr) {
+			t.Errorf("err = %v; want = *PinMismatchError", err)
+		}
+	}
+
+	r := New()
+	if err := r.SetPublicKeyPins("localhost", PublicKeyPin(other.cert)); err != nil {
+		t.Fatal(err)
+	}
+	// another Req sharing the transport does not check the pins once r
+	// moved to another one
+	shared := New()
+	shared.SetTransport(r.Transport())
+	r.SetTransport(New().Transport())
+	mismatch(r)
+	if _, err := shared.Get(url); err != nil {
+		t.Errorf("err = %v; want the pins of the previous Req ignored", err)
+	}
+
+	r.SetClient(&http.Client{Transport: New().Transport()})
+	mismatch(r)
+	r.SetClient(nil)
+	mismatch(r)
+}
@@ -0,0 +1,62 @@
+package req
+
+import (
+	"io"
+	"time"
+)
+
+// progressReader reports the bytes read from the underlying reader
+// at most once per interval, and once more when reaching the end.
+type progressReader struct {
+	io.ReadCloser
+	current  int64
+	total    int64
+	reported int64
+	interval time.Duration
+	last     time.Time
+	report   func(current, total int64)
+}
+
+func newProgressReader(rc io.ReadCloser, total int64, interval time.Duration, report func(current, total int64)) *progressReader {
+	return &progressReader{
+		ReadCloser: rc,
+		total:      total,
+		reported:   -1,
+		interval:   interval,
+		last:       time.Now(),
+		report:     report,
+	}
+}
+
+func (p *progressReader) Read(b []byte) (n int, err error) {
+	n, err = p.ReadCloser.Read(b)
+	p.current += int64(n)
+	done := err == io.EOF || (p.total > 0 && p.current >= p.total)
+	if done || time.Since(p.last) >= p.interval {
+		p.flush()
+	}
+	return
+}
+
+func (p *progressReader) flush() {
+	if p.current == p.reported {
+		return
+	}
+	p.reported = p.current
+	p.last = time.Now()
+	p.report(p.current, p.total)
+}
+
+// SetProgressInterval sets how often DownloadProgress and UploadProgress
+// are called, the default is 200 milliseconds.
+func (r *Req) SetProgressInterval(d time.Duration) {
+	r.mu.Lock()
+	r.progressInterval = d
+	r.mu.Unlock()
+}
+
+func (r *Req) getProgressInterval() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.progressInterval
+}
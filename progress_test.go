@@ -0,0 +1,97 @@
+package req
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDownloadProgress(t *testing.T) {
+	body := strings.Repeat("req", 100000)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = w.Write([]byte(body))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	r := New()
+	r.SetProgressInterval(0)
+	var calls int
+	var current, total int64
+	progress := DownloadProgress(func(c, t int64) {
+		calls++
+		current, total = c, t
+	})
+
+	resp, err := r.Get(ts.URL, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != body {
+		t.Error("response body mismatch")
+	}
+	if calls < 2 || current != int64(len(body)) || total != int64(len(body)) {
+		t.Errorf("calls = %d, progress = %d/%d; want several calls ending with %d/%d", calls, current, total, len(body), len(body))
+	}
+
+	calls = 0
+	resp, err = r.Get(ts.URL, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "body.txt")
+	if err = resp.ToFile(name); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(name); string(data) != body {
+		t.Error("file content mismatch")
+	}
+	if calls < 2 || current != int64(len(body)) {
+		t.Errorf("calls = %d, progress = %d/%d; want several calls ending with %d", calls, current, total, len(body))
+	}
+}
+
+func TestUploadProgress(t *testing.T) {
+	content := strings.Repeat("req", 100000)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	r := New()
+	r.SetProgressInterval(0)
+	var current, total int64
+	progress := UploadProgress(func(c, t int64) {
+		current, total = c, t
+	})
+
+	if _, err := r.Post(ts.URL, strings.NewReader(content), progress); err != nil {
+		t.Fatal(err)
+	}
+	if current != int64(len(content)) || total != -1 {
+		t.Errorf("progress = %d/%d; want = %d/-1", current, total, len(content))
+	}
+
+	name := filepath.Join(t.TempDir(), "upload.txt")
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload := FileUpload{File: file, FieldName: "file", FileName: "upload.txt"}
+	if _, err = r.Post(ts.URL, upload, progress); err != nil {
+		t.Fatal(err)
+	}
+	if current <= int64(len(content)) || current != total {
+		t.Errorf("progress = %d/%d; want the whole multipart body", current, total)
+	}
+}
@@ -0,0 +1,113 @@
+package req
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket allows rate tokens per second, with bursts of burst tokens.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token, it returns how long to wait until the token is
+// available.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel gives back a reserved token which was not used.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	b.tokens++
+	b.mu.Unlock()
+}
+
+// wait blocks until a token is available or ctx is done.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if err := sleepContext(ctx, b.reserve()); err != nil {
+		b.cancel()
+		return err
+	}
+	return nil
+}
+
+// SetRateLimit limits the requests of r to rate per second, with bursts
+// of burst requests. Do blocks until the request is allowed, or its
+// context is done. Every attempt of a retried request counts. A rate
+// of 0 removes the limit.
+func (r *Req) SetRateLimit(rate float64, burst int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rateLimit = nil
+	if rate > 0 {
+		r.rateLimit = newTokenBucket(rate, burst)
+	}
+}
+
+// SetHostRateLimit limits the requests of r to host, like "example.com"
+// or "example.com:8080", to rate per second, with bursts of burst
+// requests. It applies along with the limit of SetRateLimit. A rate of 0
+// removes the limit of host.
+func (r *Req) SetHostRateLimit(host string, rate float64, burst int) {
+	host = strings.ToLower(host)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rate <= 0 {
+		delete(r.hostRateLimits, host)
+		return
+	}
+	if r.hostRateLimits == nil {
+		r.hostRateLimits = make(map[string]*tokenBucket)
+	}
+	r.hostRateLimits[host] = newTokenBucket(rate, burst)
+}
+
+// waitRateLimit blocks until req is allowed by the rate limits.
+func (r *Req) waitRateLimit(req *http.Request) error {
+	r.mu.RLock()
+	limits := make([]*tokenBucket, 0, 2)
+	if r.rateLimit != nil {
+		limits = append(limits, r.rateLimit)
+	}
+	host := strings.ToLower(req.URL.Host)
+	if b, ok := r.hostRateLimits[host]; ok {
+		limits = append(limits, b)
+	} else if b, ok := r.hostRateLimits[strings.ToLower(req.URL.Hostname())]; ok {
+		limits = append(limits, b)
+	}
+	r.mu.RUnlock()
+
+	for _, b := range limits {
+		if err := b.wait(req.Context()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
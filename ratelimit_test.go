@@ -0,0 +1,83 @@
+package req
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	r := New()
+	r.SetRateLimit(20, 2)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if _, err := r.Get(ts.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a burst of 2, then 4 requests 50ms apart
+	if cost := time.Since(start); cost < 190*time.Millisecond {
+		t.Errorf("6 requests took %v; want at least 200ms", cost)
+	}
+
+	// the context is respected while waiting
+	r.SetRateLimit(1, 1)
+	if _, err := r.Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if _, err := r.Get(ts.URL, ctx); err != context.DeadlineExceeded {
+		t.Errorf("error = %v; want = %v", err, context.DeadlineExceeded)
+	}
+	if cost := time.Since(start); cost > 500*time.Millisecond {
+		t.Errorf("canceled request took %v; want it to stop waiting", cost)
+	}
+}
+
+func TestHostRateLimit(t *testing.T) {
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer limited.Close()
+	free := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer free.Close()
+
+	u, _ := url.Parse(limited.URL)
+	r := New()
+	r.SetHostRateLimit(u.Host, 10, 1)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := r.Get(free.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cost := time.Since(start); cost > 150*time.Millisecond {
+		t.Errorf("requests to another host took %v; want them unlimited", cost)
+	}
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := r.Get(limited.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cost := time.Since(start); cost < 190*time.Millisecond {
+		t.Errorf("3 requests took %v; want at least 200ms", cost)
+	}
+
+	r.SetHostRateLimit(u.Host, 0, 0)
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := r.Get(limited.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cost := time.Since(start); cost > 150*time.Millisecond {
+		t.Errorf("3 requests took %v; want the limit removed", cost)
+	}
+}
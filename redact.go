@@ -0,0 +1,239 @@
+package req
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redaction describes the secrets hidden from Resp.Dump and the debug
+// output. Set it with Req.SetRedaction, or pass it to Do to override it
+// for one request.
+type Redaction struct {
+	// Headers are the names of the request and response headers to hide.
+	Headers []string
+	// QueryParams are the names of the url query parameters to hide.
+	QueryParams []string
+	// JSONFields are the paths of the JSON body fields to hide, like
+	// "user.password". Arrays are traversed transparently and "*"
+	// matches any field name.
+	JSONFields []string
+	// FormFields are the names of the urlencoded and multipart form
+	// fields to hide.
+	FormFields []string
+	// Patterns are matched against the whole dump. When a pattern has
+	// groups, only the text of the groups is hidden.
+	Patterns []*regexp.Regexp
+	// Mask replaces the hidden values, defaults to "******".
+	Mask string
+}
+
+// DefaultRedaction returns the Redaction used by New, hiding the common
+// credential headers.
+func DefaultRedaction() *Redaction {
+	return &Redaction{
+		Headers: []string{
+			"Authorization",
+			"Proxy-Authorization",
+			"Cookie",
+			"Set-Cookie",
+			"X-Api-Key",
+			"X-Auth-Token",
+		},
+	}
+}
+
+// SetRedaction sets the secrets hidden from the dump of every request,
+// nil shows everything.
+func (r *Req) SetRedaction(redaction *Redaction) {
+	r.mu.Lock()
+	r.redaction = redaction
+	r.mu.Unlock()
+}
+
+func (r *Req) getRedaction() *Redaction {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.redaction
+}
+
+func (rd *Redaction) mask() string {
+	if rd == nil || rd.Mask == "" {
+		return "******"
+	}
+	return rd.Mask
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHead hides the values of the headers in a dumped message head.
+func (rd *Redaction) redactHead(head []byte) []byte {
+	if rd == nil || len(rd.Headers) == 0 {
+		return head
+	}
+	lines := bytes.Split(head, []byte("\r\n"))
+	// the first line is the request or status line
+	for i := 1; i < len(lines); i++ {
+		colon := bytes.IndexByte(lines[i], ':')
+		if colon <= 0 {
+			continue
+		}
+		if containsFold(rd.Headers, string(bytes.TrimSpace(lines[i][:colon]))) {
+			lines[i] = append(lines[i][:colon:colon], ": "+rd.mask()...)
+		}
+	}
+	return bytes.Join(lines, []byte("\r\n"))
+}
+
+// redactHeader returns a copy of h with the headers hidden.
+func (rd *Redaction) redactHeader(h http.Header) http.Header {
+	if rd == nil || len(rd.Headers) == 0 {
+		return h
+	}
+	h = h.Clone()
+	for key := range h {
+		if containsFold(rd.Headers, key) {
+			h[key] = []string{rd.mask()}
+		}
+	}
+	return h
+}
+
+// redactValues hides the values of names in an urlencoded string,
+// keeping the order of the pairs.
+func (rd *Redaction) redactValues(s string, names []string) string {
+	if len(names) == 0 || s == "" {
+		return s
+	}
+	pairs := strings.Split(s, "&")
+	for i, pair := range pairs {
+		key := pair
+		if j := strings.IndexByte(pair, '='); j >= 0 {
+			key = pair[:j]
+		}
+		if k, err := url.QueryUnescape(key); err == nil && containsFold(names, k) {
+			pairs[i] = key + "=" + url.QueryEscape(rd.mask())
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactURL returns a copy of u with the query parameters hidden.
+func (rd *Redaction) redactURL(u *url.URL) *url.URL {
+	nu := new(url.URL)
+	*nu = *u
+	if rd != nil {
+		nu.RawQuery = rd.redactValues(u.RawQuery, rd.QueryParams)
+		if _, ok := nu.User.Password(); ok {
+			nu.User = url.UserPassword(nu.User.Username(), rd.mask())
+		}
+	}
+	return nu
+}
+
+// redactForm returns a copy of form with the form fields hidden.
+func (rd *Redaction) redactForm(form url.Values) url.Values {
+	if rd == nil || len(rd.FormFields) == 0 {
+		return form
+	}
+	redacted := make(url.Values, len(form))
+	for key, values := range form {
+		if containsFold(rd.FormFields, key) {
+			masked := make([]string, len(values))
+			for i := range masked {
+				masked[i] = rd.mask()
+			}
+			values = masked
+		}
+		redacted[key] = values
+	}
+	return redacted
+}
+
+// redactBody hides the JSON or form fields of a body of the given
+// content type.
+func (rd *Redaction) redactBody(body []byte, contentType string) []byte {
+	if rd == nil || len(body) == 0 {
+		return body
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded" && len(rd.FormFields) > 0:
+		return []byte(rd.redactValues(string(body), rd.FormFields))
+	case (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) && len(rd.JSONFields) > 0:
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return body
+		}
+		for _, path := range rd.JSONFields {
+			v = rd.redactJSON(v, strings.Split(path, "."))
+		}
+		if data, err := json.Marshal(v); err == nil {
+			return data
+		}
+	}
+	return body
+}
+
+func (rd *Redaction) redactJSON(v interface{}, path []string) interface{} {
+	switch vv := v.(type) {
+	case []interface{}:
+		for i := range vv {
+			vv[i] = rd.redactJSON(vv[i], path)
+		}
+	case map[string]interface{}:
+		for key, value := range vv {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+			if len(path) == 1 {
+				vv[key] = rd.mask()
+			} else {
+				vv[key] = rd.redactJSON(value, path[1:])
+			}
+		}
+	}
+	return v
+}
+
+// redactText hides the matches of the patterns in s.
+func (rd *Redaction) redactText(s string) string {
+	if rd == nil {
+		return s
+	}
+	for _, re := range rd.Patterns {
+		if re.NumSubexp() == 0 {
+			s = re.ReplaceAllLiteralString(s, rd.mask())
+			continue
+		}
+		var b strings.Builder
+		last := 0
+		for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+			for g := 2; g < len(m); g += 2 {
+				// skip the groups which did not match
+				if m[g] < last {
+					continue
+				}
+				b.WriteString(s[last:m[g]])
+				b.WriteString(rd.mask())
+				last = m[g+1]
+			}
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+	return s
+}
@@ -0,0 +1,68 @@
+package req
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret-session"})
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"user":{"name":"roc","token":"secret-token"},"items":[{"key":"secret-key"}]}`))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	r := New()
+	r.SetBasicAuth("roc", "secret-password")
+	resp, err := r.Post(ts.URL+"/?access_token=secret-query&page=1",
+		Header{"X-Api-Key": "secret-api-key", "Cookie": "id=secret-cookie"},
+		Param{"password": "secret-form", "name": "roc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// default redaction hides the credential headers only
+	dump := resp.Dump()
+	for _, secret := range []string{"secret-password", "secret-api-key", "secret-cookie", "secret-session"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("dump contains %s", secret)
+		}
+	}
+	for _, visible := range []string{"secret-query", "secret-form", "secret-token"} {
+		if !strings.Contains(dump, visible) {
+			t.Errorf("dump lacks %s", visible)
+		}
+	}
+
+	resp, err = r.Post(ts.URL+"/?access_token=secret-query&page=1",
+		Param{"password": "secret-form", "name": "roc"},
+		&Redaction{
+			QueryParams: []string{"access_token"},
+			FormFields:  []string{"password"},
+			JSONFields:  []string{"user.token", "items.key"},
+			Patterns:    []*regexp.Regexp{regexp.MustCompile(`"name":"(\w+)"`)},
+			Mask:        "xxx",
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump = resp.Dump()
+	for _, secret := range []string{"secret-query", "secret-form", "secret-token", "secret-key", `"name":"roc"`} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("dump contains %s", secret)
+		}
+	}
+	for _, visible := range []string{"access_token=xxx&page=1", "password=xxx", `"token":"xxx"`, `"name":"xxx"`, "secret-session"} {
+		if !strings.Contains(dump, visible) {
+			t.Errorf("dump lacks %s", visible)
+		}
+	}
+	if resp.Request().URL.Query().Get("access_token") != "secret-query" {
+		t.Error("redaction changed the request")
+	}
+}
@@ -17,7 +17,10 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/text/encoding"
 )
 
 const (
@@ -54,12 +57,21 @@ type FileUpload struct {
 	ContentType string
 }
 
+// DownloadProgress is called with the number of bytes of the response body
+// read so far, total is -1 when the length is unknown.
 type DownloadProgress func(current, total int64)
 
+// UploadProgress is called with the number of bytes of the request body
+// sent so far, total is -1 when the length is unknown.
 type UploadProgress func(current, total int64)
 
 type FormData string
 
+// RequestCharset is the charset of the Param, QueryParam, url.Values,
+// FormData and multipart form fields of a request, like "gbk" or
+// "shift_jis". See Req.SetRequestCharset.
+type RequestCharset string
+
 type AllowRedirects bool
 
 // File upload files matching the name pattern such as
@@ -92,6 +104,10 @@ func File(patterns ...string) interface{} {
 	return uploads
 }
 
+// multipartForm makes the form of a request multipart/form-data even
+// without any FileUpload, like the -F option of curl does.
+type multipartForm struct{}
+
 type bodyJson struct {
 	v interface{}
 }
@@ -110,28 +126,64 @@ func BodyXML(v interface{}) *bodyXml {
 	return &bodyXml{v: v}
 }
 
-// Req is a convenient client for initiating requests
+// Req is a convenient client for initiating requests.
+//
+// A Req only holds defaults, every Do call builds its own *http.Request
+// from them, so a single Req can be shared by multiple goroutines.
 type Req struct {
 	client           *http.Client
 	jsonEncOpts      *jsonEncOpts
 	xmlEncOpts       *xmlEncOpts
 	progressInterval time.Duration
-	Req              *http.Request
 	flag             int
+
+	mu      sync.RWMutex
+	header  http.Header
+	cookies []*http.Cookie
+	auth    *BasicAuth
+	retry   *RetryPolicy
+	debug   bool
+	logger  Logger
+
+	redaction   *Redaction
+	autoCharset bool
+	charset     string
+
+	baseURL string
+	query   url.Values
+
+	rateLimit      *tokenBucket
+	hostRateLimits map[string]*tokenBucket
+	breaker        *CircuitBreaker
+	pins           *pinner
+
+	middlewares []Middleware
+	beforeHooks []RequestHook
+	afterHooks  []ResponseHook
 }
 
 // New create a new *Req
 func New() *Req {
 	// default progress reporting interval is 200 milliseconds
-	req := &http.Request{
-		//Method:     method,
+	return &Req{
+		client:           newClient(),
+		flag:             LstdFlags,
+		progressInterval: 200 * time.Millisecond,
+		header:           make(http.Header),
+		redaction:        DefaultRedaction(),
+	}
+}
 
+// newRequest builds a fresh *http.Request, the defaults of r are merged
+// in once the options of the request are known.
+func newRequest(method string) *http.Request {
+	return &http.Request{
+		Method:     method,
 		Header:     make(http.Header),
 		Proto:      "HTTP/1.1",
 		ProtoMajor: 1,
 		ProtoMinor: 1,
 	}
-	return &Req{Req: req, client: newClient(), flag: LstdFlags}
 }
 
 type param struct {
@@ -173,50 +225,105 @@ func (p *param) Empty() bool {
 // Do execute a http request with sepecify method and url,
 // and it can also have some optional params, depending on your needs.
 func (r *Req) Do(method, rawurl string, vs ...interface{}) (resp *Resp, err error) {
-	if rawurl == "" {
-		return nil, errors.New("req: url not specified")
+	resp, retry, debug, lastFunc, err := r.build(method, rawurl, vs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.send(resp, retry)
+	for _, fn := range lastFunc {
+		fn()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// output detail if debug is enabled
+	if debug {
+		r.getLogger().Println(resp.Dump())
 	}
+	return
+}
 
-	//ctx, cancelFunc := context.WithTimeout(context.Background(), 1*time.Minute)
-	////ctx, cancelFunc := context.WithCancel(context.Background())
-	//defer cancelFunc()
-	//req = req.WithContext(ctx)
-	//r.Req.Close = true
-	resp = &Resp{req: r.Req, r: r}
-	r.Req.Method = method
+// build prepares the request of Do without sending it, lastFunc must be
+// called once the request is done.
+func (r *Req) build(method, rawurl string, vs []interface{}) (resp *Resp, retry *RetryPolicy, debug bool, lastFunc []func(), err error) {
+	defaults := r.session()
+	rawurl = defaults.resolve(rawurl)
+	if rawurl == "" {
+		return nil, nil, false, nil, errors.New("req: url not specified")
+	}
+	// the funcs closing the request body, run here if the request is not
+	// sent since the caller only gets them without error
+	var pending []func()
+	defer func() {
+		if err != nil {
+			for _, fn := range pending {
+				fn()
+			}
+		}
+	}()
+
+	req := newRequest(method)
+	resp = &Resp{
+		req:              req,
+		r:                r,
+		progressInterval: r.getProgressInterval(),
+		flag:             r.Flags(),
+		redaction:        r.getRedaction(),
+		autoCharset:      r.autoCharsetEnabled(),
+	}
+	debug = r.debugEnabled()
 
 	//allowRedirects := true
 
 	var queryParam param
 	var formParam param
+	var cookies []*http.Cookie
 	var uploads []FileUpload
+	var multipart bool
 	var delayedFunc []func()
-	var lastFunc []func()
+	var resume Resume
+	retry = r.getRetry()
+
+	// the charset applies to the parameters given before it as well
+	charset := r.getRequestCharset()
+	for _, v := range vs {
+		if c, ok := v.(RequestCharset); ok {
+			charset = string(c)
+		}
+	}
+	var enc encoding.Encoding
+	if charset != "" {
+		if enc = lookupEncoding(charset); enc == nil {
+			return nil, nil, false, nil, fmt.Errorf("req: unsupported charset %q", charset)
+		}
+	}
 
 	for _, v := range vs {
 		switch vv := v.(type) {
 		case Header:
 			for key, value := range vv {
-				r.Req.Header.Add(key, value)
+				req.Header.Add(key, value)
 			}
 		case http.Header:
 			for key, values := range vv {
 				for _, value := range values {
-					r.Req.Header.Add(key, value)
+					req.Header.Add(key, value)
 				}
 			}
 		case BasicAuth:
-			r.Req.SetBasicAuth(vv.Username, vv.Password)
+			req.SetBasicAuth(vv.Username, vv.Password)
 		case *bodyJson:
-			fn, err := setBodyJson(r.Req, resp, r.jsonEncOpts, vv.v)
+			fn, err := setBodyJson(req, resp, r.jsonEncOpts, vv.v)
 			if err != nil {
-				return nil, err
+				return nil, nil, false, nil, err
 			}
 			delayedFunc = append(delayedFunc, fn)
 		case *bodyXml:
-			fn, err := setBodyXml(r.Req, resp, r.xmlEncOpts, vv.v)
+			fn, err := setBodyXml(req, resp, r.xmlEncOpts, vv.v)
 			if err != nil {
-				return nil, err
+				return nil, nil, false, nil, err
 			}
 			delayedFunc = append(delayedFunc, fn)
 		case url.Values:
@@ -227,8 +334,14 @@ func (r *Req) Do(method, rawurl string, vs ...interface{}) (resp *Resp, err erro
 				formParam.Copy(p)
 			}
 		case FormData:
-			setBodyBytes(r.Req, resp, []byte(vv))
-			r.Req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			data := string(vv)
+			if enc != nil {
+				if data, err = encodeCharset(data, enc); err != nil {
+					return nil, nil, false, nil, err
+				}
+			}
+			setBodyBytes(req, resp, []byte(data))
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 		case Param:
 			if method == "GET" || method == "HEAD" {
 				queryParam.Adds(vv)
@@ -238,64 +351,102 @@ func (r *Req) Do(method, rawurl string, vs ...interface{}) (resp *Resp, err erro
 		case QueryParam:
 			queryParam.Adds(vv)
 		case string:
-			setBodyBytes(r.Req, resp, []byte(vv))
+			setBodyBytes(req, resp, []byte(vv))
 		case []byte:
-			setBodyBytes(r.Req, resp, vv)
+			setBodyBytes(req, resp, vv)
 		case bytes.Buffer:
-			setBodyBytes(r.Req, resp, vv.Bytes())
+			setBodyBytes(req, resp, vv.Bytes())
 		case *http.Client:
 			resp.client = vv
+		case *RetryPolicy:
+			retry = vv
+		case DownloadProgress:
+			resp.downloadProgress = vv
+		case UploadProgress:
+			resp.uploadProgress = vv
+		case Resume:
+			resume = vv
+		case *Redaction:
+			resp.redaction = vv
+		case DumpFlags:
+			resp.flag = int(vv)
+			debug = vv != 0
 		case FileUpload:
 			//if vv.ContentType!="" {
-			//	r.Req.Header.Set("Content-Type",vv.ContentType)
+			//	req.Header.Set("Content-Type",vv.ContentType)
 			//}
 
 			uploads = append(uploads, vv)
 		case []FileUpload:
 			uploads = append(uploads, vv...)
+		case multipartForm:
+			multipart = true
 		case *http.Cookie:
-			r.Req.AddCookie(vv)
+			cookies = append(cookies, vv)
 		case Host:
-			r.Req.Host = string(vv)
+			req.Host = string(vv)
 		case io.Reader:
-			fn := setBodyReader(r.Req, resp, vv)
-			lastFunc = append(lastFunc, fn)
+			fn := setBodyReader(req, resp, vv)
+			pending = append(pending, fn)
 
 		case context.Context:
-			r.Req = r.Req.WithContext(vv)
-			resp.req = r.Req
+			req = req.WithContext(vv)
+			resp.req = req
 
 		case error:
-			return nil, vv
+			return nil, nil, false, nil, vv
+		}
+	}
+	// the options of the request replace the defaults
+	defaults.merge(req, rawurl, &queryParam, cookies)
+
+	if enc != nil {
+		if queryParam.Values, err = encodeValues(queryParam.Values, enc); err != nil {
+			return nil, nil, false, nil, err
+		}
+		if formParam.Values, err = encodeValues(formParam.Values, enc); err != nil {
+			return nil, nil, false, nil, err
 		}
 	}
-	if r.Req.Header.Get("User-Agent") == "" || r.Req.Header.Get("user-agent") == "" {
-		r.Req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.77 Safari/537.36")
+	if req.Header.Get("User-Agent") == "" || req.Header.Get("user-agent") == "" {
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.77 Safari/537.36")
 	}
 
-	if length := r.Req.Header.Get("Content-Length"); length != "" {
+	if length := req.Header.Get("Content-Length"); length != "" {
 		if l, err := strconv.ParseInt(length, 10, 64); err == nil {
-			r.Req.ContentLength = l
+			req.ContentLength = l
 		}
 	}
 
-	if len(uploads) > 0 && (r.Req.Method == "POST" || r.Req.Method == "PUT") { // multipart
+	if len(uploads) > 0 || multipart {
+		if req.Method == "GET" || req.Method == "HEAD" {
+			for _, up := range uploads {
+				if up.File != nil {
+					_ = up.File.Close()
+				}
+			}
+			return nil, nil, false, nil, fmt.Errorf("req: %s request can not have a multipart body", req.Method)
+		}
 
 		multipartHelper := &multipartHelper{
 			form:    formParam.Values,
 			uploads: uploads,
 		}
-		multipartHelper.UploadX(r.Req)
+		multipartHelper.UploadX(req)
+		pending = append(pending, multipartHelper.Close)
 
 		resp.multipartHelper = multipartHelper
 	} else {
 
 		if !formParam.Empty() {
-			if r.Req.Body != nil {
+			if req.Body != nil {
 				queryParam.Copy(formParam)
 			} else {
-				setBodyBytes(r.Req, resp, []byte(formParam.Encode()))
-				setContentType(r.Req, "application/x-www-form-urlencoded; charset=UTF-8")
+				setBodyBytes(req, resp, []byte(formParam.Encode()))
+				if charset == "" {
+					charset = "UTF-8"
+				}
+				setContentType(req, "application/x-www-form-urlencoded; charset="+charset)
 			}
 		}
 	}
@@ -311,12 +462,16 @@ func (r *Req) Do(method, rawurl string, vs ...interface{}) (resp *Resp, err erro
 
 	u, err := url.Parse(rawurl)
 	if err != nil {
-		return nil, err
+		return nil, nil, false, nil, err
 	}
-	r.Req.URL = u
+	req.URL = u
 
-	if host := r.Req.Header.Get("Host"); host != "" {
-		r.Req.Host = host
+	if resume != "" {
+		resp.resume = setResume(req, string(resume))
+	}
+
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
 	}
 
 	for _, fn := range delayedFunc {
@@ -326,24 +481,7 @@ func (r *Req) Do(method, rawurl string, vs ...interface{}) (resp *Resp, err erro
 	if resp.client == nil {
 		resp.client = r.Client()
 	}
-
-	var response *http.Response
-	response, err = resp.client.Do(r.Req)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, fn := range lastFunc {
-		fn()
-	}
-
-	resp.resp = response
-
-	//// output detail if Debug is enabled
-	if Debug {
-		fmt.Println(resp.Dump())
-	}
-	return
+	return resp, retry, debug, pending, nil
 }
 
 func (r *Req) DisableAllowRedirects() {
@@ -357,6 +495,9 @@ func setBodyBytes(req *http.Request, resp *Resp, data []byte) {
 	resp.reqBody = data
 	req.Body = ioutil.NopCloser(bytes.NewReader(data))
 	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
 }
 
 func setBodyJson(req *http.Request, resp *Resp, opts *jsonEncOpts, v interface{}) (func(), error) {
@@ -454,6 +595,25 @@ func setBodyReader(req *http.Request, resp *Resp, rd io.Reader) func() {
 		limit:      102400,
 	}
 	req.Body = bw
+	resp.reqBodyWrapper = bw
+	if seeker, ok := rd.(io.Seeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			// the transport closes the body after each attempt,
+			// so rc is only closed once all attempts are done
+			bw.ReadCloser = ioutil.NopCloser(rc)
+			req.GetBody = func() (io.ReadCloser, error) {
+				if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+					return nil, err
+				}
+				return ioutil.NopCloser(rd), nil
+			}
+			return func() {
+				_ = rc.Close()
+				resp.reqBody = bw.buf.Bytes()
+			}
+		}
+	}
+	req.GetBody = bw.replay
 	lastFunc := func() {
 		resp.reqBody = bw.buf.Bytes()
 	}
@@ -464,10 +624,16 @@ type bodyWrapper struct {
 	io.ReadCloser
 	buf   bytes.Buffer
 	limit int
+	eof   bool
+	n     int
 }
 
 func (b *bodyWrapper) Read(p []byte) (n int, err error) {
 	n, err = b.ReadCloser.Read(p)
+	b.n += n
+	if err == io.EOF {
+		b.eof = true
+	}
 	if left := b.limit - b.buf.Len(); left > 0 && n > 0 {
 		if n <= left {
 			b.buf.Write(p[:n])
@@ -478,15 +644,38 @@ func (b *bodyWrapper) Read(p []byte) (n int, err error) {
 	return
 }
 
+// complete reports whether the whole body was read and recorded, it
+// does not fit in the record limit otherwise.
+func (b *bodyWrapper) complete() bool {
+	return b.eof && b.n <= b.buf.Len()
+}
+
+// replay returns the recorded body, which is only possible
+// when the whole body fits in the record limit.
+func (b *bodyWrapper) replay() (io.ReadCloser, error) {
+	if !b.complete() {
+		return nil, errBodyNotReplayable
+	}
+	return ioutil.NopCloser(bytes.NewReader(b.buf.Bytes())), nil
+}
+
 type multipartHelper struct {
-	form             url.Values
-	uploads          []FileUpload
-	dump             []byte
-	uploadProgress   UploadProgress
-	progressInterval time.Duration
-	ContentType      string
+	form        url.Values
+	uploads     []FileUpload
+	ContentType string
+
+	boundary string
+	offsets  []int64 // start offsets of seekable files, nil if any is not
+	mu       sync.Mutex
+	heads    [][]byte // beginning of each file, for the dump
+	sizes    []int64  // bytes sent of each file
+	pr       *io.PipeReader
+	done     chan struct{}
 }
 
+// dumpFileLimit is the number of bytes of each file shown in the dump.
+const dumpFileLimit = 512
+
 var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
 
 func escapeQuotes(s string) string {
@@ -520,50 +709,218 @@ func (m *multipartHelper) Upload(req *http.Request) {
 
 }
 
+// Dump returns a rendition of the multipart body, with every file
+// truncated to its first bytes.
 func (m *multipartHelper) Dump() []byte {
-	if m.dump != nil {
-		return m.dump
-	}
+	return m.dump(m.form)
+}
+
+// dump renders the multipart body with the given form fields.
+func (m *multipartHelper) dump(form url.Values) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	var buf bytes.Buffer
 	bodyWriter := multipart.NewWriter(&buf)
-	for key, values := range m.form {
+	if m.boundary != "" {
+		_ = bodyWriter.SetBoundary(m.boundary)
+	}
+	for key, values := range form {
 		for _, value := range values {
 			_ = m.writeField(bodyWriter, key, value)
 		}
 	}
 
-	for _, up := range m.uploads {
-		_, _ = m.writeFile(bodyWriter, up.FieldName, up.FileName, up.ContentType)
-
+	for i, up := range m.uploads {
+		p, err := m.writeFile(bodyWriter, up.FieldName, up.FileName, up.ContentType)
+		if err != nil || i >= len(m.heads) {
+			continue
+		}
+		_, _ = p.Write(m.heads[i])
+		if m.sizes[i] > int64(len(m.heads[i])) {
+			_, _ = fmt.Fprintf(p, "\r\n... (%d bytes)", m.sizes[i])
+		}
 	}
 	_ = bodyWriter.Close()
-	m.dump = buf.Bytes()
-	return m.dump
+	return buf.Bytes()
 }
 
+// UploadX streams the multipart body to req through a pipe. The
+// Content-Length is set when the sizes of all files are known, and the
+// body can be replayed when all files are seekable.
 func (m *multipartHelper) UploadX(req *http.Request) {
+	bodyWriter := multipart.NewWriter(ioutil.Discard)
+	m.boundary = bodyWriter.Boundary()
+	req.Header.Set("Content-Type", bodyWriter.FormDataContentType())
+	req.ContentLength = m.contentLength()
+	if req.ContentLength < 0 {
+		// unknown, the body is sent chunked
+		req.ContentLength = 0
+	}
 
-	var buf bytes.Buffer
-	bodyWriter := multipart.NewWriter(&buf)
+	m.offsets = make([]int64, len(m.uploads))
+	for i, up := range m.uploads {
+		seeker, ok := up.File.(io.Seeker)
+		if !ok {
+			m.offsets = nil
+			break
+		}
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			m.offsets = nil
+			break
+		}
+		m.offsets[i] = offset
+	}
+
+	req.Body = m.stream()
+	if m.offsets != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			m.stop()
+			for i, up := range m.uploads {
+				if _, err := up.File.(io.Seeker).Seek(m.offsets[i], io.SeekStart); err != nil {
+					return nil, err
+				}
+			}
+			return m.stream(), nil
+		}
+	}
+}
+
+// contentLength returns the length of the multipart body,
+// or -1 if the size of any file is unknown.
+func (m *multipartHelper) contentLength() int64 {
+	cw := new(countWriter)
+	bodyWriter := multipart.NewWriter(cw)
+	_ = bodyWriter.SetBoundary(m.boundary)
 	for key, values := range m.form {
 		for _, value := range values {
 			_ = m.writeField(bodyWriter, key, value)
 		}
 	}
-
 	for _, up := range m.uploads {
+		size := uploadSize(up.File)
+		if size < 0 {
+			return -1
+		}
+		_, _ = m.writeFile(bodyWriter, up.FieldName, up.FileName, up.ContentType)
+		cw.n += size
+	}
+	_ = bodyWriter.Close()
+	return cw.n
+}
+
+// uploadSize returns the number of bytes left in f, or -1 if unknown.
+func uploadSize(f io.Reader) int64 {
+	switch v := f.(type) {
+	case *os.File:
+		stat, err := v.Stat()
+		if err != nil || !stat.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return stat.Size() - offset
+	case interface{ Len() int }:
+		return int64(v.Len())
+	}
+	return -1
+}
+
+// stream starts writing the multipart body to a pipe, returning its reader.
+func (m *multipartHelper) stream() io.ReadCloser {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	m.mu.Lock()
+	m.pr, m.done = pr, done
+	m.heads = make([][]byte, len(m.uploads))
+	m.sizes = make([]int64, len(m.uploads))
+	m.mu.Unlock()
+	go func() {
+		defer close(done)
+		bodyWriter := multipart.NewWriter(pw)
+		_ = bodyWriter.SetBoundary(m.boundary)
+		_ = pw.CloseWithError(m.writeTo(bodyWriter))
+	}()
+	return pr
+}
+
+func (m *multipartHelper) writeTo(bodyWriter *multipart.Writer) error {
+	for key, values := range m.form {
+		for _, value := range values {
+			if err := m.writeField(bodyWriter, key, value); err != nil {
+				return err
+			}
+		}
+	}
+	for i, up := range m.uploads {
 		write, err := m.writeFile(bodyWriter, up.FieldName, up.FileName, up.ContentType)
 		if err != nil {
-			continue
+			return err
+		}
+		head := &headBuffer{limit: dumpFileLimit}
+		n, err := io.Copy(io.MultiWriter(write, head), up.File)
+		m.mu.Lock()
+		m.heads[i], m.sizes[i] = head.Bytes(), n
+		m.mu.Unlock()
+		if err != nil {
+			return err
 		}
-		_, _ = io.Copy(write, up.File)
+	}
+	return bodyWriter.Close()
+}
 
+// stop aborts the body being streamed, and waits for it to be done.
+func (m *multipartHelper) stop() {
+	m.mu.Lock()
+	pr, done := m.pr, m.done
+	m.mu.Unlock()
+	if pr != nil {
+		_ = pr.Close()
+		<-done
 	}
-	_ = bodyWriter.Close()
-	m.dump = buf.Bytes()
-	req.ContentLength = int64(buf.Len())
-	req.Header.Set("Content-Type", bodyWriter.FormDataContentType())
-	req.Body = ioutil.NopCloser(&buf)
+}
+
+// Close stops streaming the body and closes the uploaded files.
+func (m *multipartHelper) Close() {
+	m.mu.Lock()
+	if m.pr != nil {
+		_ = m.pr.Close()
+	}
+	m.mu.Unlock()
+	for _, up := range m.uploads {
+		if up.File != nil {
+			_ = up.File.Close()
+		}
+	}
+}
+
+// countWriter counts the bytes written to it.
+type countWriter struct {
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// headBuffer keeps the first limit bytes written to it.
+type headBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	if left := b.limit - b.Len(); left > 0 {
+		if len(p) > left {
+			b.Buffer.Write(p[:left])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
 }
 
 func (m *multipartHelper) writeField(w *multipart.Writer, fieldname, value string) error {
@@ -632,103 +989,113 @@ func (r *Req) Options(url string, v ...interface{}) (*Resp, error) {
 	return r.Do("OPTIONS", url, v...)
 }
 
+// SetBasicAuth sets the basic auth sent with every request
 func (r *Req) SetBasicAuth(username, password string) {
-	r.Req.SetBasicAuth(username, password)
+	r.mu.Lock()
+	r.auth = &BasicAuth{Username: username, Password: password}
+	r.mu.Unlock()
 }
 
+// AddCookies adds cookies sent with every request
 func (r *Req) AddCookies(cookieMap map[string]string) {
 	if cookieMap == nil {
 		return
 	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	for k, v := range cookieMap {
-		cookie := &http.Cookie{Name: k, Value: v}
-		r.Req.AddCookie(cookie)
+		r.cookies = append(r.cookies, &http.Cookie{Name: k, Value: v})
 	}
 }
 
+// AddHeader adds headers sent with every request
 func (r *Req) AddHeader(headers map[string]string) {
 	if headers == nil {
 		return
 	}
-
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	for k, v := range headers {
-		r.Req.Header.Add(k, v)
+		r.header.Add(k, v)
 	}
-
 }
 
+// UpdateCookie sets cookies sent with every request, replacing
+// the ones with the same name. cookies can be a map[string]string
+// or a string like "a=1; b=2".
 func (r *Req) UpdateCookie(cookies interface{}) {
-	switch cookies.(type) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch cc := cookies.(type) {
 	case map[string]string:
-		if cmap, ok := cookies.(map[string]string); ok {
-			for k, v := range cmap {
-				cc := &http.Cookie{Name: k, Value: v}
-				r.Req.AddCookie(cc)
-			}
+		for k, v := range cc {
+			r.setCookie(&http.Cookie{Name: k, Value: v})
 		}
-
 	case string:
-		if cstr, ok := cookies.(string); ok {
-			c := strings.Split(cstr, ";")
-			for _, value := range c {
-				cv := strings.Split(value, "=")
-				if len(cv) == 2 {
-
-					cc := &http.Cookie{Name: cv[0], Value: cv[1]}
-					r.Req.AddCookie(cc)
-				}
+		for _, value := range strings.Split(cc, ";") {
+			cv := strings.SplitN(strings.TrimSpace(value), "=", 2)
+			if len(cv) == 2 {
+				r.setCookie(&http.Cookie{Name: cv[0], Value: cv[1]})
 			}
 		}
-
 	}
-
 }
 
+// setCookie must be called with r.mu held.
+func (r *Req) setCookie(cookie *http.Cookie) {
+	for i, c := range r.cookies {
+		if c.Name == cookie.Name {
+			r.cookies[i] = cookie
+			return
+		}
+	}
+	r.cookies = append(r.cookies, cookie)
+}
 
 // Get execute a http GET request
 func Get(url string, v ...interface{}) (*Resp, error) {
-	r := New()
+	r := newShared()
 	return r.Get(url, v...)
 }
 
 // Post execute a http POST request
 func Post(url string, v ...interface{}) (*Resp, error) {
-	r := New()
+	r := newShared()
 	return r.Post(url, v...)
 }
 
 // Put execute a http PUT request
 func Put(url string, v ...interface{}) (*Resp, error) {
-	r := New()
+	r := newShared()
 	return r.Put(url, v...)
 }
 
 // Head execute a http HEAD request
 func Head(url string, v ...interface{}) (*Resp, error) {
-	r := New()
+	r := newShared()
 	return r.Head(url, v...)
 }
 
 // Options execute a http OPTIONS request
 func Options(url string, v ...interface{}) (*Resp, error) {
-	r := New()
+	r := newShared()
 	return r.Options(url, v...)
 }
 
 // Delete execute a http DELETE request
 func Delete(url string, v ...interface{}) (*Resp, error) {
-	r := New()
+	r := newShared()
 	return r.Delete(url, v...)
 }
 
 // Patch execute a http PATCH request
 func Patch(url string, v ...interface{}) (*Resp, error) {
-	r := New()
+	r := newShared()
 	return r.Patch(url, v...)
 }
 
 // Do execute request.
 func Do(method, url string, v ...interface{}) (*Resp, error) {
-	r := New()
+	r := newShared()
 	return r.Do(method, url, v...)
 }
@@ -9,8 +9,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestUrlParam(t *testing.T) {
@@ -431,3 +434,126 @@ func TestReq_Options(t *testing.T) {
 	}
 	fmt.Println(resp.String())
 }
+
+func TestConcurrentDo(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Values("X-Default"); len(v) != 1 {
+			t.Errorf("X-Default = %v; want exactly one value", v)
+		}
+		if v := r.Header.Values("X-Call"); len(v) != 1 {
+			t.Errorf("X-Call = %v; want exactly one value", v)
+		}
+		if user, _, _ := r.BasicAuth(); user != "roc" {
+			t.Errorf("basic auth user = %s; want = roc", user)
+		}
+		_, _ = w.Write([]byte(r.Header.Get("X-Call")))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	r := New()
+	r.AddHeader(map[string]string{"X-Default": "req"})
+	r.SetBasicAuth("roc", "123")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			call := fmt.Sprint(i)
+			resp, err := r.Post(ts.URL, Header{"X-Call": call}, Param{"i": i})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if resp.String() != call {
+				t.Errorf("response body = %s; want = %s", resp.String(), call)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestMultipartUpload(t *testing.T) {
+	content := strings.Repeat("hello req ", 1000)
+	var lengths []int64
+	var fails int
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		lengths = append(lengths, r.ContentLength)
+		if r.FormValue("name") != "roc" {
+			t.Errorf("form value name = %s; want = roc", r.FormValue("name"))
+		}
+		file, header, err := r.FormFile("media")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		data, _ := ioutil.ReadAll(file)
+		if string(data) != content || header.Filename != "hello.txt" {
+			t.Errorf("file %s content length = %d; want hello.txt with length %d", header.Filename, len(data), len(content))
+		}
+		if fails > 0 {
+			fails--
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	name := filepath.Join(t.TempDir(), "hello.txt")
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+
+	// known size, sent with a Content-Length and replayed on retry
+	fails = 1
+	policy := NewRetryPolicy(2)
+	policy.Backoff = func(n int) time.Duration { return 0 }
+	resp, err := r.Post(ts.URL, File(name), Param{"name": "roc"}, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lengths) != 2 || lengths[0] <= int64(len(content)) || lengths[0] != lengths[1] {
+		t.Errorf("content lengths = %v; want two equal lengths", lengths)
+	}
+	dump := string(resp.multipartHelper.Dump())
+	if !strings.Contains(dump, content[:dumpFileLimit]) || strings.Contains(dump, content[:dumpFileLimit+1]) {
+		t.Error("dump does not hold the truncated file")
+	}
+	if !strings.Contains(dump, fmt.Sprintf("... (%d bytes)", len(content))) {
+		t.Error("dump does not hold the file size")
+	}
+
+	// unknown size, sent chunked
+	lengths = nil
+	upload := FileUpload{
+		File:      ioutil.NopCloser(strings.NewReader(content)),
+		FieldName: "media",
+		FileName:  "hello.txt",
+	}
+	if _, err = r.Post(ts.URL, upload, Param{"name": "roc"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(lengths) != 1 || lengths[0] != -1 {
+		t.Errorf("content lengths = %v; want = [-1]", lengths)
+	}
+}
+
+func TestUploadBuildError(t *testing.T) {
+	f, err := ioutil.TempFile("", "req-upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("file content")
+	f.Seek(0, 0)
+
+	_, err = New().Post("http://example.com/%zz", FileUpload{FieldName: "file", FileName: "a.txt", File: f})
+	if err == nil {
+		t.Fatal("no error with an invalid url")
+	}
+	if _, err := f.Stat(); err == nil {
+		t.Error("upload file not closed")
+	}
+}
@@ -1,17 +1,20 @@
 package req
 
 import (
+	"bufio"
 	"bytes"
-	"compress/gzip"
-	"compress/zlib"
 	"encoding/json"
 	"encoding/xml"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"sync"
 	"time"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
 )
 
 // Resp represents a request with it's response
@@ -25,6 +28,21 @@ type Resp struct {
 	reqBody  []byte
 	respBody []byte
 	err      error // delayed error
+	attempts []Attempt
+
+	downloadProgress DownloadProgress
+	uploadProgress   UploadProgress
+	progressInterval time.Duration
+	resume           *resumeState
+	trace            *tracer
+	flag             int
+	redaction        *Redaction
+	autoCharset      bool
+
+	// reqBodyWrapper records a request body read from an io.Reader
+	reqBodyWrapper *bodyWrapper
+	// decoded is the response body with its content codings decoded
+	decoded io.ReadCloser
 }
 
 var bytesNewBufferpool = sync.Pool{
@@ -55,6 +73,15 @@ func (r *Resp) Header()   http.Header{
 	return r.resp.Header
 }
 
+// requestBody returns the request body, the beginning of it
+// when it was read from an io.Reader
+func (r *Resp) requestBody() []byte {
+	if r.reqBody == nil && r.reqBodyWrapper != nil {
+		return r.reqBodyWrapper.buf.Bytes()
+	}
+	return r.reqBody
+}
+
 // Bytes returns response body as []byte
 func (r *Resp) Bytes() []byte {
 	data, _ := r.ToBytes()
@@ -62,6 +89,9 @@ func (r *Resp) Bytes() []byte {
 }
 
 func (r *Resp) Close() {
+	if r.decoded != nil {
+		_ = r.decoded.Close()
+	}
 	if r.resp.Body != nil {
 		//_, _ = io.Copy(ioutil.Discard, r.resp.Body)
 		err := r.resp.Body.Close()
@@ -83,26 +113,10 @@ func (r *Resp) ToBytes() ([]byte, error) {
 	if r.respBody != nil {
 		return r.respBody, nil
 	}
-	var reader io.ReadCloser
-	var err error
-	//Accept-Encoding
-	encoding := r.resp.Header.Get("Content-Encoding")
-	if encoding == "" {
-		encoding = r.resp.Header.Get("Accept-Encoding")
-	}
-	switch encoding {
-	case "gzip", "gzip, deflate":
-		if reader, err = gzip.NewReader(r.resp.Body); err != nil {
-			return nil, err
-		}
-	case "deflate":
-		if reader, err = zlib.NewReader(r.resp.Body); err != nil {
-			return nil, err
-		}
-	default:
-		reader = r.resp.Body
+	reader, err := r.body()
+	if err != nil {
+		return nil, err
 	}
-
 	defer reader.Close()
 
 	b := bytesNewBufferpool.Get().(*bytes.Buffer)
@@ -114,7 +128,8 @@ func (r *Resp) ToBytes() ([]byte, error) {
 
 		return nil, err
 	}
-	r.respBody = b.Bytes()
+	// b goes back to the pool, so keep a copy of its content
+	r.respBody = append([]byte(nil), b.Bytes()...)
 	return r.respBody, nil
 }
 
@@ -138,23 +153,28 @@ func (r *Resp) LimitReaderBytes(n int64) ([]byte, error) {
 		return r.respBody, nil
 	}
 
+	body, err := r.body()
+	if err != nil {
+		return nil, err
+	}
+
 	b := bytesNewBufferpool.Get().(*bytes.Buffer)
 	b.Reset()
 	defer bytesNewBufferpool.Put(b)
 
-	lr := io.LimitReader(r.resp.Body, n)
+	lr := io.LimitReader(body, n)
 
-	_, err := io.Copy(b, lr)
+	_, err = io.Copy(b, lr)
 	if err != nil {
 		return nil, err
 	}
-	return b.Bytes(), nil
+	return append([]byte(nil), b.Bytes()...), nil
 }
 
 // String returns response body as string
 func (r *Resp) String() string {
-	data, _ := r.ToBytes()
-	return string(data)
+	s, _ := r.ToString()
+	return s
 }
 
 func (resp *Resp) URL() (*url.URL, error) {
@@ -176,18 +196,57 @@ func (resp *Resp) URL() (*url.URL, error) {
 // the response body
 func (r *Resp) ToString() (string, error) {
 	data, err := r.ToBytes()
+	if err == nil && r.autoCharset {
+		data = r.decodeCharset(data)
+	}
 	return string(data), err
 }
 
+// ToUTF8String converts content from the charset of the response to UTF-8
 func (r *Resp) ToUTF8String(content []byte) string {
-	html := string(content)
-	encoding := GetEncoding(content, r.Response().Header)
+	return string(r.decodeCharset(content))
+}
 
-	if encoding != "" {
-		html = EncodingConvertToUtf8(html, encoding)
+// decodeCharset transcodes content to UTF-8 from the charset declared by
+// the document or the response header.
+func (r *Resp) decodeCharset(content []byte) []byte {
+	e := lookupEncoding(GetEncoding(content, r.resp.Header))
+	if e == nil {
+		e = unicode.UTF8
+	}
+	data, err := decodeToUTF8(content, e)
+	if err != nil {
+		return content
 	}
+	return data
+}
 
-	return html
+// UTF8Reader returns the response body transcoded to UTF-8 as it is read,
+// for bodies too large to be read at once. The charset is detected from
+// the response header or the first 1024 bytes of the body.
+func (r *Resp) UTF8Reader() (io.ReadCloser, error) {
+	var body io.ReadCloser
+	if r.respBody != nil {
+		body = ioutil.NopCloser(bytes.NewReader(r.respBody))
+	} else {
+		var err error
+		if body, err = r.body(); err != nil {
+			return nil, err
+		}
+	}
+	br := bufio.NewReaderSize(body, 1024)
+	preview, err := br.Peek(1024)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	e := lookupEncoding(GetEncoding(preview, r.resp.Header))
+	if e == nil {
+		e = unicode.UTF8
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{transform.NewReader(br, newDecoder(e)), body}, nil
 }
 
 // ToJSON convert json response body to struct or map
@@ -196,20 +255,37 @@ func (r *Resp) ToJSON(v interface{}) error {
 	if err != nil {
 		return err
 	}
+	if r.autoCharset {
+		data = r.decodeCharset(data)
+	}
 	return json.Unmarshal(data, v)
 }
 
-// ToXML convert xml response body to struct or map
+// ToXML convert xml response body to struct or map,
+// the encoding declared by the document is supported
 func (r *Resp) ToXML(v interface{}) error {
 	data, err := r.ToBytes()
 	if err != nil {
 		return err
 	}
-	return xml.Unmarshal(data, v)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = charsetReader
+	if r.autoCharset {
+		dec = xml.NewDecoder(bytes.NewReader(r.decodeCharset(data)))
+		dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+			// already transcoded
+			return input, nil
+		}
+	}
+	return dec.Decode(v)
 }
 
-// ToFile download the response body to file with optional download callback
+// ToFile download the response body to file with optional download callback,
+// a download started with Resume continues the partial file.
 func (r *Resp) ToFile(name string) error {
+	if r.resume != nil && r.resume.name == name {
+		return r.resumeToFile(name)
+	}
 	//TODO set name to the suffix of url path if name == ""
 	file, err := os.Create(name)
 	if err != nil {
@@ -222,16 +298,45 @@ func (r *Resp) ToFile(name string) error {
 		return err
 	}
 
-	defer r.resp.Body.Close()
-	_, err = io.Copy(file, r.resp.Body)
-	return err
+	return r.download(file)
+}
+
+// body returns the response body with its content codings decoded,
+// reporting the download progress if a DownloadProgress is set. The
+// progress counts the bytes received, before decoding.
+func (r *Resp) body() (io.ReadCloser, error) {
+	if r.decoded != nil {
+		return r.decoded, nil
+	}
+	var body io.ReadCloser = r.resp.Body
+	if r.downloadProgress != nil {
+		pr := newProgressReader(body, r.resp.ContentLength, r.progressInterval, r.downloadProgress)
+		if r.resume != nil && r.resp.StatusCode == http.StatusPartialContent && pr.total >= 0 {
+			// report the progress of the whole file
+			pr.current = r.resume.offset
+			pr.total += r.resume.offset
+		}
+		body = pr
+	}
+	if r.resp.StatusCode != http.StatusPartialContent {
+		// a range of an encoded body can not be decoded on its own
+		decoded, err := decodeBody(body, r.resp.Header)
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+	r.decoded = body
+	return body, nil
 }
 
 func (r *Resp) download(file *os.File) error {
-	p := make([]byte, 1024)
-	b := r.resp.Body
+	p := make([]byte, 32*1024)
+	b, err := r.body()
+	if err != nil {
+		return err
+	}
 	defer b.Close()
-	var current int64
 	for {
 		l, err := b.Read(p)
 		if l > 0 {
@@ -239,8 +344,6 @@ func (r *Resp) download(file *os.File) error {
 			if _err != nil {
 				return _err
 			}
-			current += int64(l)
-
 		}
 		if err != nil {
 			if err == io.EOF {
@@ -0,0 +1,179 @@
+package req
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Resume makes Do continue the download of a partially downloaded file
+// with the given name. Only the missing bytes are requested with a Range
+// header, guarded by If-Range, and Resp.ToFile appends them to the file.
+// When the server ignores the range or the file changed, ToFile downloads
+// the whole file again.
+type Resume string
+
+// resumeSuffix names the file keeping the validators of a partial download.
+const resumeSuffix = ".resume"
+
+// resumeInfo is saved next to a partial download, so that it can be
+// checked that the remote file did not change before resuming it.
+type resumeInfo struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+type resumeState struct {
+	name   string
+	offset int64
+}
+
+// RangeMismatchError is returned by Resp.ToFile when the range sent by the
+// server does not continue the partial file.
+type RangeMismatchError struct {
+	Offset       int64
+	ContentRange string
+}
+
+func (e *RangeMismatchError) Error() string {
+	return fmt.Sprintf("req: content range %q does not start at offset %d", e.ContentRange, e.Offset)
+}
+
+func loadResumeInfo(name string) (*resumeInfo, error) {
+	data, err := ioutil.ReadFile(name + resumeSuffix)
+	if err != nil {
+		return nil, err
+	}
+	info := new(resumeInfo)
+	if err = json.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func saveResumeInfo(name string, info *resumeInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name+resumeSuffix, data, 0644)
+}
+
+// setResume adds the range headers needed to resume the download of name.
+func setResume(req *http.Request, name string) *resumeState {
+	state := &resumeState{name: name}
+	// ranges apply to the encoded content, so ask for the file as is
+	req.Header.Set("Accept-Encoding", "identity")
+	stat, err := os.Stat(name)
+	if err != nil || stat.Size() == 0 {
+		return state
+	}
+	info, err := loadResumeInfo(name)
+	if err != nil || info.URL != req.URL.String() {
+		return state
+	}
+	state.offset = stat.Size()
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", state.offset))
+	if info.ETag != "" && !strings.HasPrefix(info.ETag, "W/") {
+		req.Header.Set("If-Range", info.ETag)
+	} else if info.LastModified != "" {
+		req.Header.Set("If-Range", info.LastModified)
+	}
+	return state
+}
+
+// parseContentRange parses a Content-Range header like "bytes 0-99/1000"
+// or "bytes */1000", returning -1 for the unknown parts.
+func parseContentRange(v string) (start, end, size int64, ok bool) {
+	if !strings.HasPrefix(v, "bytes ") {
+		return 0, 0, 0, false
+	}
+	v = strings.TrimPrefix(v, "bytes ")
+	i := strings.IndexByte(v, '/')
+	if i < 0 {
+		return 0, 0, 0, false
+	}
+	size = -1
+	if s := v[i+1:]; s != "*" {
+		var err error
+		if size, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return 0, 0, 0, false
+		}
+	}
+	if v[:i] == "*" {
+		return -1, -1, size, true
+	}
+	bounds := strings.SplitN(v[:i], "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, 0, false
+	}
+	var err1, err2 error
+	start, err1 = strconv.ParseInt(bounds[0], 10, 64)
+	end, err2 = strconv.ParseInt(bounds[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, 0, false
+	}
+	return start, end, size, true
+}
+
+// resumeToFile writes the response of a resumed download to name.
+func (r *Resp) resumeToFile(name string) error {
+	defer r.resp.Body.Close()
+	state := r.resume
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	switch r.resp.StatusCode {
+	case http.StatusOK:
+		// the server ignored the range, or the file changed
+	case http.StatusPartialContent:
+		cr := r.resp.Header.Get("Content-Range")
+		if start, _, _, ok := parseContentRange(cr); !ok || start != state.offset {
+			return &RangeMismatchError{Offset: state.offset, ContentRange: cr}
+		}
+		flag = os.O_WRONLY
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial file may already be complete
+		cr := r.resp.Header.Get("Content-Range")
+		if _, _, size, ok := parseContentRange(cr); ok && size == state.offset {
+			return os.Remove(name + resumeSuffix)
+		}
+		return &RangeMismatchError{Offset: state.offset, ContentRange: cr}
+	default:
+		return fmt.Errorf("req: can not resume download, status %s", r.resp.Status)
+	}
+
+	info := &resumeInfo{
+		URL:          r.req.URL.String(),
+		ETag:         r.resp.Header.Get("ETag"),
+		LastModified: r.resp.Header.Get("Last-Modified"),
+	}
+	if err := saveResumeInfo(name, info); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(name, flag, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if flag&os.O_TRUNC == 0 {
+		if err = file.Truncate(state.offset); err != nil {
+			return err
+		}
+		if _, err = file.Seek(state.offset, 0); err != nil {
+			return err
+		}
+	}
+	if r.respBody != nil {
+		_, err = file.Write(r.respBody)
+	} else {
+		err = r.download(file)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(name + resumeSuffix)
+}
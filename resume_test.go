@@ -0,0 +1,105 @@
+package req
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRangeServer(content *string, etag *string, ranges *[]string) *httptest.Server {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", *etag)
+		http.ServeContent(w, r, "file.txt", time.Time{}, strings.NewReader(*content))
+	}
+	return httptest.NewServer(http.HandlerFunc(handler))
+}
+
+func TestResume(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	etag := `"v1"`
+	var ranges []string
+	ts := newRangeServer(&content, &etag, &ranges)
+	defer ts.Close()
+
+	r := New()
+	name := filepath.Join(t.TempDir(), "file.txt")
+	check := func(want string) {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("file content length = %d; want = %d", len(data), len(want))
+		}
+		if _, err = os.Stat(name + resumeSuffix); !os.IsNotExist(err) {
+			t.Errorf("resume file still exists: %v", err)
+		}
+	}
+	download := func() {
+		resp, err := r.Get(ts.URL, Resume(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = resp.ToFile(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// nothing to resume
+	download()
+	check(content)
+	if ranges[0] != "" {
+		t.Errorf("range = %s; want none", ranges[0])
+	}
+
+	// interrupted download
+	interrupt := func() {
+		_ = ioutil.WriteFile(name, []byte(content[:4000]), 0644)
+		_ = saveResumeInfo(name, &resumeInfo{URL: ts.URL, ETag: `"v1"`})
+	}
+	interrupt()
+	download()
+	check(content)
+	if ranges[1] != "bytes=4000-" {
+		t.Errorf("range = %s; want = bytes=4000-", ranges[1])
+	}
+
+	// the remote file changed, If-Range makes the server send it all
+	interrupt()
+	etag = `"v2"`
+	content = strings.Repeat("abcdefghij", 900)
+	download()
+	check(content)
+
+	// the partial file is already complete
+	_ = saveResumeInfo(name, &resumeInfo{URL: ts.URL, ETag: etag})
+	download()
+	check(content)
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		v                string
+		start, end, size int64
+		ok               bool
+	}{
+		{"bytes 0-99/1000", 0, 99, 1000, true},
+		{"bytes 100-199/*", 100, 199, -1, true},
+		{"bytes */1000", -1, -1, 1000, true},
+		{"bytes 1-/1000", 0, 0, 0, false},
+		{"items 0-1/2", 0, 0, 0, false},
+	}
+	for _, test := range tests {
+		start, end, size, ok := parseContentRange(test.v)
+		if start != test.start || end != test.end || size != test.size || ok != test.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %d, %v; want = %d, %d, %d, %v",
+				test.v, start, end, size, ok, test.start, test.end, test.size, test.ok)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package req
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryCondition reports whether an attempt should be retried,
+// resp.Response() is nil when err is a transport error.
+type RetryCondition func(resp *Resp, err error) bool
+
+// Backoff returns how long to wait before the n-th retry, n starts at 1.
+type Backoff func(n int) time.Duration
+
+// RetryPolicy controls how failed requests are retried. It can be set on
+// a Req with SetRetry, or passed to Do to override it for one request.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int
+	// Backoff returns the delay before each retry, defaults to
+	// ExponentialBackoff(100*time.Millisecond, 10*time.Second).
+	Backoff Backoff
+	// Conditions decide whether an attempt is retried, a retry happens when
+	// any of them returns true. Defaults to RetryOnNetworkError,
+	// RetryOnServerError and RetryOnTooManyRequests.
+	Conditions []RetryCondition
+	// IgnoreRetryAfter disables honoring the Retry-After response header.
+	IgnoreRetryAfter bool
+	// MaxRetryAfter is the longest Retry-After delay honored, the request
+	// is not retried when the server asks to wait longer. Defaults to
+	// one minute.
+	MaxRetryAfter time.Duration
+	// OnRetry is called before waiting for each retry.
+	OnRetry func(resp *Resp, attempt Attempt)
+}
+
+// NewRetryPolicy returns a RetryPolicy with the default backoff and
+// conditions, making at most maxAttempts attempts.
+func NewRetryPolicy(maxAttempts int) *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: maxAttempts}
+}
+
+// Attempt records the outcome of one attempt of a request.
+type Attempt struct {
+	// Num is the attempt number, starting at 1.
+	Num int
+	// StatusCode is the response status code, 0 on transport errors.
+	StatusCode int
+	// Err is the transport error of the attempt, if any.
+	Err error
+	// Cost is the time spent by the attempt.
+	Cost time.Duration
+	// Wait is the delay before the next attempt, 0 for the last one.
+	Wait time.Duration
+}
+
+var errBodyNotReplayable = errors.New("req: request body can not be replayed")
+
+// RetryOnNetworkError retries on transport errors, except when the
+// request context is done or the server does not match its pins.
+func RetryOnNetworkError(resp *Resp, err error) bool {
+	if err == nil || resp.req.Context().Err() != nil {
+		return false
+	}
+	var pinErr *PinMismatchError
+	return !errors.As(err, &pinErr)
+}
+
+// RetryOnServerError retries on 5xx responses.
+func RetryOnServerError(resp *Resp, err error) bool {
+	return err == nil && resp.resp.StatusCode >= 500 && resp.resp.StatusCode <= 599
+}
+
+// RetryOnTooManyRequests retries on 429 responses.
+func RetryOnTooManyRequests(resp *Resp, err error) bool {
+	return RetryOnStatus(http.StatusTooManyRequests)(resp, err)
+}
+
+// RetryOnStatus retries on responses with any of the given status codes.
+func RetryOnStatus(codes ...int) RetryCondition {
+	return func(resp *Resp, err error) bool {
+		if err != nil {
+			return false
+		}
+		for _, code := range codes {
+			if resp.resp.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// ExponentialBackoff doubles the delay from min for every retry, up to max,
+// and picks a random delay between half of it and the full delay.
+func ExponentialBackoff(min, max time.Duration) Backoff {
+	return func(n int) time.Duration {
+		d := min
+		for i := 1; i < n && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		if d <= 0 {
+			return 0
+		}
+		jitterMu.Lock()
+		j := time.Duration(jitterRand.Int63n(int64(d)/2 + 1))
+		jitterMu.Unlock()
+		return d/2 + j
+	}
+}
+
+var defaultBackoff = ExponentialBackoff(100*time.Millisecond, 10*time.Second)
+
+var defaultRetryConditions = []RetryCondition{
+	RetryOnNetworkError,
+	RetryOnServerError,
+	RetryOnTooManyRequests,
+}
+
+func (p *RetryPolicy) shouldRetry(resp *Resp, err error) bool {
+	conditions := p.Conditions
+	if len(conditions) == 0 {
+		conditions = defaultRetryConditions
+	}
+	for _, cond := range conditions {
+		if cond(resp, err) {
+			return true
+		}
+	}
+	return false
+}
+
+const defaultMaxRetryAfter = time.Minute
+
+// wait returns the delay before the n-th retry, false if the Retry-After
+// delay of response is too long to wait.
+func (p *RetryPolicy) wait(n int, response *http.Response) (time.Duration, bool) {
+	if !p.IgnoreRetryAfter && response != nil {
+		if d, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+			max := p.MaxRetryAfter
+			if max <= 0 {
+				max = defaultMaxRetryAfter
+			}
+			return d, d <= max
+		}
+	}
+	backoff := p.Backoff
+	if backoff == nil {
+		backoff = defaultBackoff
+	}
+	return backoff(n), true
+}
+
+// parseRetryAfter parses the Retry-After header, which is either
+// a number of seconds or a http date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// SetRetry sets the retry policy of every request, nil disables retrying.
+func (r *Req) SetRetry(policy *RetryPolicy) {
+	r.mu.Lock()
+	r.retry = policy
+	r.mu.Unlock()
+}
+
+func (r *Req) getRetry() *RetryPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.retry
+}
+
+// send sends the request of resp, retrying it according to policy,
+// and records every attempt on resp.
+func (r *Req) send(resp *Resp, policy *RetryPolicy) error {
+	defer func(start time.Time) {
+		resp.cost = time.Since(start)
+	}(time.Now())
+	req := resp.req
+	breaker := r.getCircuitBreaker()
+	for n := 1; ; n++ {
+		// forget the body of the previous attempt, a hook or a retry
+		// condition may have read it
+		if resp.decoded != nil {
+			_ = resp.decoded.Close()
+			resp.decoded = nil
+		}
+		resp.respBody = nil
+		if resp.uploadProgress != nil && req.Body != nil && req.Body != http.NoBody {
+			total := req.ContentLength
+			if total <= 0 {
+				total = -1
+			}
+			req.Body = newProgressReader(req.Body, total, resp.progressInterval, resp.uploadProgress)
+		}
+		host := strings.ToLower(req.URL.Host)
+		if breaker != nil {
+			if err := breaker.allow(host); err != nil {
+				return err
+			}
+		}
+		if err := r.waitRateLimit(req); err != nil {
+			if breaker != nil {
+				breaker.done(host, req.Context(), nil, err)
+			}
+			return err
+		}
+		start := time.Now()
+		response, err := r.roundTrip(resp, req)
+		if breaker != nil {
+			breaker.done(host, req.Context(), response, err)
+		}
+		if he, ok := err.(*hookError); ok {
+			return he.err
+		}
+		attempt := Attempt{Num: n, Err: err, Cost: time.Since(start)}
+		if response != nil {
+			attempt.StatusCode = response.StatusCode
+		}
+
+		retry := policy != nil && n < policy.MaxAttempts && policy.shouldRetry(resp, err)
+		if retry {
+			var wait time.Duration
+			if wait, retry = policy.wait(n, response); retry {
+				attempt.Wait = wait
+			}
+		}
+		var next *http.Request
+		if retry {
+			// this attempt is the last one if the body can not be replayed
+			var rewindErr error
+			if next, rewindErr = rewindRequest(resp.req); rewindErr != nil {
+				retry = false
+				attempt.Wait = 0
+			}
+		}
+		resp.attempts = append(resp.attempts, attempt)
+		if !retry {
+			return err
+		}
+
+		if policy.OnRetry != nil {
+			policy.OnRetry(resp, attempt)
+		}
+		if response != nil {
+			// drain the body so that the connection can be reused
+			_, _ = io.Copy(ioutil.Discard, response.Body)
+			_ = response.Body.Close()
+		}
+		if err := sleepContext(req.Context(), attempt.Wait); err != nil {
+			return err
+		}
+		req = next
+	}
+}
+
+// rewindRequest returns a copy of req with a fresh body obtained
+// from req.GetBody.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	nreq := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return nreq, nil
+	}
+	if req.GetBody == nil {
+		return nil, errBodyNotReplayable
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	nreq.Body = body
+	return nreq, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Attempts returns every attempt made to get the response.
+func (r *Resp) Attempts() []Attempt {
+	return r.attempts
+}
@@ -0,0 +1,218 @@
+package req
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newFlakyServer(t *testing.T, failures int32, status int, body string) (*httptest.Server, *int32) {
+	var count int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(data) != body {
+			t.Errorf("request body = %s; want = %s", data, body)
+		}
+		if atomic.AddInt32(&count, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}
+	return httptest.NewServer(http.HandlerFunc(handler)), &count
+}
+
+func TestRetry(t *testing.T) {
+	body := "request body"
+	policy := &RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     func(n int) time.Duration { return time.Millisecond },
+	}
+	var retries int
+	policy.OnRetry = func(resp *Resp, attempt Attempt) {
+		retries++
+		if attempt.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("attempt status = %d; want = %d", attempt.StatusCode, http.StatusServiceUnavailable)
+		}
+	}
+	r := New()
+	r.SetRetry(policy)
+
+	bodies := map[string]interface{}{
+		"string":     body,
+		"reader":     strings.NewReader(body),
+		"unseekable": ioutil.NopCloser(strings.NewReader(body)),
+	}
+	for name, v := range bodies {
+		ts, count := newFlakyServer(t, 2, http.StatusServiceUnavailable, body)
+		retries = 0
+		resp, err := r.Post(ts.URL, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.String() != "ok" {
+			t.Errorf("%s: response body = %s; want = ok", name, resp.String())
+		}
+		if n := len(resp.Attempts()); n != 3 || *count != 3 || retries != 2 {
+			t.Errorf("%s: attempts = %d, requests = %d, retries = %d; want = 3, 3, 2", name, n, *count, retries)
+		}
+		ts.Close()
+	}
+}
+
+func TestRetryGiveUp(t *testing.T) {
+	ts, count := newFlakyServer(t, 5, http.StatusTooManyRequests, "")
+	defer ts.Close()
+	r := New()
+	policy := NewRetryPolicy(2)
+	policy.Backoff = func(n int) time.Duration { return 0 }
+	resp, err := r.Get(ts.URL, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Response().StatusCode != http.StatusTooManyRequests || *count != 2 {
+		t.Errorf("status = %d, requests = %d; want = 429, 2", resp.Response().StatusCode, *count)
+	}
+
+	// only retry on the given conditions
+	*count = 0
+	policy.Conditions = []RetryCondition{RetryOnServerError}
+	if _, err = r.Get(ts.URL, policy); err != nil {
+		t.Fatal(err)
+	}
+	if *count != 1 {
+		t.Errorf("requests = %d; want = 1", *count)
+	}
+}
+
+func TestRetryBodyNotReplayable(t *testing.T) {
+	// above the record limit of the body, so it can not be replayed
+	body := strings.Repeat("x", 200*1024)
+	ts, count := newFlakyServer(t, 1, http.StatusServiceUnavailable, body)
+	defer ts.Close()
+	policy := NewRetryPolicy(3)
+	policy.Backoff = func(n int) time.Duration { return 0 }
+	resp, err := New().Post(ts.URL, ioutil.NopCloser(strings.NewReader(body)), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Response().StatusCode != http.StatusServiceUnavailable || *count != 1 {
+		t.Errorf("status = %d, requests = %d; want = 503, 1", resp.Response().StatusCode, *count)
+	}
+	if attempts := resp.Attempts(); len(attempts) != 1 || attempts[0].Wait != 0 {
+		t.Errorf("attempts = %+v; want one attempt without wait", attempts)
+	}
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	var count int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	r := New()
+	policy := NewRetryPolicy(3)
+	start := time.Now()
+	resp, err := r.Get(ts.URL, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || time.Since(start) > 5*time.Second {
+		t.Errorf("requests = %d after %v; want = 1 without waiting", count, time.Since(start))
+	}
+	if attempts := resp.Attempts(); len(attempts) != 1 || attempts[0].Wait != 0 {
+		t.Errorf("attempts = %+v; want one attempt without wait", attempts)
+	}
+
+	// a longer limit
+	policy.MaxRetryAfter = 48 * time.Hour
+	if d, ok := policy.wait(1, resp.Response()); !ok || d != 24*time.Hour {
+		t.Errorf("wait = %v, %v; want = 24h, true", d, ok)
+	}
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+	r := New()
+	policy := NewRetryPolicy(3)
+	policy.Backoff = func(n int) time.Duration { return 0 }
+	var attempts []Attempt
+	policy.OnRetry = func(resp *Resp, attempt Attempt) {
+		attempts = append(attempts, attempt)
+	}
+	_, err := r.Get(ts.URL, policy)
+	if err == nil {
+		t.Fatal("error = nil; want connection error")
+	}
+	if len(attempts) != 2 || attempts[0].Err == nil {
+		t.Errorf("attempts = %+v; want 2 failed attempts", attempts)
+	}
+}
+
+func TestRetryReadBody(t *testing.T) {
+	ts, _ := newFlakyServer(t, 1, http.StatusServiceUnavailable, "")
+	defer ts.Close()
+	r := New()
+	var bodies []string
+	r.OnAfterResponse(func(resp *Resp) error {
+		bodies = append(bodies, resp.String())
+		return nil
+	})
+	policy := NewRetryPolicy(3)
+	policy.Backoff = func(n int) time.Duration { return 0 }
+	policy.Conditions = []RetryCondition{func(resp *Resp, err error) bool {
+		return err == nil && resp.String() != "ok"
+	}}
+	resp, err := r.Get(ts.URL, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := resp.String(); body != "ok" {
+		t.Errorf("body = %q; want = %q", body, "ok")
+	}
+	if len(bodies) != 2 || bodies[1] != "ok" {
+		t.Errorf("hook bodies = %q; want = [\"\" \"ok\"]", bodies)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := parseRetryAfter("3"); !ok || d != 3*time.Second {
+		t.Errorf("parseRetryAfter(3) = %v, %v; want = 3s, true", d, ok)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(date); !ok || d < 59*time.Minute {
+		t.Errorf("parseRetryAfter(%s) = %v, %v; want about 1h", date, d, ok)
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Error("parseRetryAfter(soon) ok = true; want = false")
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(100*time.Millisecond, time.Second)
+	for n, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		if d := backoff(n + 1); d < max/2 || d > max {
+			t.Errorf("backoff(%d) = %v; want between %v and %v", n+1, d, max/2, max)
+		}
+	}
+}
+
+func TestRewindRequest(t *testing.T) {
+	req, _ := http.NewRequest("POST", "http://example.com", ioutil.NopCloser(strings.NewReader("a")))
+	req.GetBody = nil
+	if _, err := rewindRequest(req); !errors.Is(err, errBodyNotReplayable) {
+		t.Errorf("error = %v; want = %v", err, errBodyNotReplayable)
+	}
+}
@@ -0,0 +1,127 @@
+package req
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// session holds the defaults of a Req merged into every request.
+type session struct {
+	baseURL string
+	header  http.Header
+	query   url.Values
+	cookies []*http.Cookie
+	auth    *BasicAuth
+}
+
+func (r *Req) session() *session {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s := &session{
+		baseURL: r.baseURL,
+		header:  r.header.Clone(),
+		cookies: append([]*http.Cookie(nil), r.cookies...),
+		auth:    r.auth,
+	}
+	if r.query != nil {
+		s.query = make(url.Values, len(r.query))
+		for key, values := range r.query {
+			s.query[key] = append([]string(nil), values...)
+		}
+	}
+	return s
+}
+
+// SetBaseURL sets the URL that the relative URLs of requests are
+// appended to, so that r.Get("/users/1") requests the path /users/1
+// under rawurl. Absolute URLs are requested as is.
+func (r *Req) SetBaseURL(rawurl string) {
+	r.mu.Lock()
+	r.baseURL = rawurl
+	r.mu.Unlock()
+}
+
+// SetHeader sets a header sent with every request, replacing its
+// values. A header given to Do replaces it for that request.
+func (r *Req) SetHeader(key, value string) {
+	r.mu.Lock()
+	r.header.Set(key, value)
+	r.mu.Unlock()
+}
+
+// SetQueryParams sets query parameters sent with every request,
+// replacing the values of the same keys. A parameter given to Do,
+// or in the url, replaces it for that request.
+func (r *Req) SetQueryParams(params QueryParam) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.query == nil {
+		r.query = make(url.Values)
+	}
+	for key, value := range params {
+		r.query.Set(key, fmt.Sprint(value))
+	}
+}
+
+// resolve returns rawurl appended to the base URL if it is relative.
+func (s *session) resolve(rawurl string) string {
+	if s.baseURL == "" {
+		return rawurl
+	}
+	if u, err := url.Parse(rawurl); err == nil && (u.IsAbs() || u.Host != "") {
+		return rawurl
+	}
+	if rawurl == "" || strings.HasPrefix(rawurl, "?") {
+		return s.baseURL + rawurl
+	}
+	return strings.TrimSuffix(s.baseURL, "/") + "/" + strings.TrimPrefix(rawurl, "/")
+}
+
+// merge adds the defaults to req and query which are not given for the
+// request, cookies are the cookies given for the request.
+func (s *session) merge(req *http.Request, rawurl string, query *param, cookies []*http.Cookie) {
+	for key, values := range s.header {
+		if _, ok := req.Header[key]; !ok {
+			req.Header[key] = values
+		}
+	}
+	if s.auth != nil && req.Header.Get("Authorization") == "" {
+		req.SetBasicAuth(s.auth.Username, s.auth.Password)
+	}
+
+	names := make(map[string]bool, len(cookies))
+	for _, c := range cookies {
+		names[c.Name] = true
+	}
+	for _, c := range s.cookies {
+		if !names[c.Name] {
+			req.AddCookie(c)
+		}
+	}
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	if len(s.query) == 0 {
+		return
+	}
+	var inURL url.Values
+	if i := strings.IndexByte(rawurl, '?'); i >= 0 {
+		rawQuery := rawurl[i+1:]
+		if j := strings.IndexByte(rawQuery, '#'); j >= 0 {
+			rawQuery = rawQuery[:j]
+		}
+		inURL, _ = url.ParseQuery(rawQuery)
+	}
+	for key, values := range s.query {
+		if _, ok := inURL[key]; ok {
+			continue
+		}
+		if _, ok := query.Values[key]; ok {
+			continue
+		}
+		query.getValues()[key] = values
+	}
+}
@@ -0,0 +1,77 @@
+package req
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	var got *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer ts.Close()
+
+	r := New()
+	r.SetBaseURL(ts.URL + "/api/")
+	r.SetHeader("X-Client", "req")
+	r.SetHeader("Accept", "application/json")
+	r.SetQueryParams(QueryParam{"token": "abc", "lang": "en"})
+	r.UpdateCookie("session=1; theme=dark")
+	r.SetBasicAuth("roc", "pass")
+
+	if _, err := r.Get("/users/1"); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/api/users/1" || got.URL.Query().Get("token") != "abc" || got.URL.Query().Get("lang") != "en" {
+		t.Errorf("url = %s; want the base url and default query", got.URL)
+	}
+	if got.Header.Get("X-Client") != "req" || got.Header.Get("Cookie") != "session=1; theme=dark" {
+		t.Errorf("header = %v; want the defaults", got.Header)
+	}
+	if username, _, _ := got.BasicAuth(); username != "roc" {
+		t.Errorf("username = %s; want = roc", username)
+	}
+
+	// the options of the request replace the defaults
+	_, err := r.Get("users/2?lang=fr", Header{"Accept": "text/html"}, QueryParam{"token": "xyz"},
+		&http.Cookie{Name: "theme", Value: "light"}, BasicAuth{Username: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/api/users/2" || got.URL.RawQuery != "lang=fr&token=xyz" {
+		t.Errorf("url = %s; want the query of the request", got.URL)
+	}
+	if accept := got.Header.Values("Accept"); len(accept) != 1 || accept[0] != "text/html" {
+		t.Errorf("accept = %v; want = [text/html]", accept)
+	}
+	if cookie := got.Header.Get("Cookie"); cookie != "session=1; theme=light" {
+		t.Errorf("cookie = %s; want = session=1; theme=light", cookie)
+	}
+	if username, _, _ := got.BasicAuth(); username != "other" {
+		t.Errorf("username = %s; want = other", username)
+	}
+
+	// absolute urls ignore the base url
+	if _, err = r.Get(ts.URL + "/other"); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/other" || !strings.Contains(got.URL.RawQuery, "token=abc") {
+		t.Errorf("url = %s; want = /other with the default query", got.URL)
+	}
+	// a url in the query does not make the url absolute
+	if _, err = r.Get("/login?next=https://example.com/home"); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/api/login" || got.URL.Query().Get("next") != "https://example.com/home" {
+		t.Errorf("url = %s; want = /api/login with the next query", got.URL)
+	}
+	if _, err = r.Get(""); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/api/" {
+		t.Errorf("path = %s; want the base url", got.URL.Path)
+	}
+}
@@ -3,16 +3,23 @@ package req
 import (
 	"crypto/tls"
 	"errors"
-	"golang.org/x/net/publicsuffix"
+	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
+	"sync"
 	"time"
 )
 
 var Timeout int = 15
 
+// VerifyTLS makes every new Req verify the server certificates if set to
+// true, see Req.EnableInsecureTLS to set it for one Req
+var VerifyTLS bool
+
 var (
+	// httpTransport is the template of the transport of every Req,
+	// each Req gets its own clone and the package level functions share
+	// one
 	httpTransport *http.Transport
 )
 
@@ -21,27 +28,64 @@ func init() {
 		TLSClientConfig: &tls.Config{
 			Renegotiation:      tls.RenegotiateOnceAsClient,
 			InsecureSkipVerify: true},
-		//Dial: func(netw, addr string) (net.Conn, error) {
-		//	c, err := net.DialTimeout(netw, addr, time.Second*15) //设置建立连接超时
-		//	if err != nil {
-		//		return nil, err
-		//	}
-		//	_ = c.SetDeadline(time.Now().Add(15 * time.Second)) //设置发送接收数据超时
-		//	return c, nil
-		//},
-		DisableKeepAlives:   true,
-		MaxIdleConnsPerHost: 1,
-		MaxIdleConns:        1,
+		DialContext:           newDialer(30 * time.Second).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
 	}
 }
-func newClient() *http.Client {
-	options := cookiejar.Options{
-		PublicSuffixList: publicsuffix.List,
+
+func newDialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
 	}
-	jar, _ := cookiejar.New(&options)
+}
+
+func newTransport() *http.Transport {
+	trans := httpTransport.Clone()
+	trans.TLSClientConfig.InsecureSkipVerify = !VerifyTLS
+	// HTTP/2 is set up before the first request so that net/http finds it
+	// and closes its idle connections too, it is negotiated only if
+	// enabled by SetALPN
+	_ = configureHTTP2(trans)
+	trans.TLSClientConfig.NextProtos = nil
+	return trans
+}
+
+var (
+	sharedMu         sync.Mutex
+	sharedTransports = make(map[bool]*http.Transport)
+)
+
+// sharedTransport returns the transport shared by the package level
+// functions, like Get, so they reuse their connections
+func sharedTransport() *http.Transport {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	trans := sharedTransports[VerifyTLS]
+	if trans == nil {
+		trans = newTransport()
+		sharedTransports[VerifyTLS] = trans
+	}
+	return trans
+}
+
+// newShared returns the Req of a package level function, it keeps its own
+// cookies but shares the transport
+func newShared() *Req {
+	r := New()
+	r.SetTransport(sharedTransport())
+	return r
+}
+
+func newClient() *http.Client {
+	jar, _ := NewJar(nil)
 	return &http.Client{
 		Jar:       jar,
-		Transport: httpTransport,
+		Transport: newTransport(),
 		Timeout:   time.Duration(Timeout) * time.Second,
 	}
 }
@@ -57,6 +101,21 @@ func (r *Req) Client() *http.Client {
 // SetClient sets the underlying http.Client.
 func (r *Req) SetClient(client *http.Client) {
 	r.client = client // use default if client == nil
+	r.reinstallPins()
+}
+
+// Transport returns the transport of the underlying http.Client.
+func (r *Req) Transport() http.RoundTripper {
+	return r.Client().Transport
+}
+
+// SetTransport sets the transport of the underlying http.Client. Every
+// Req has its own transport, so the settings of one Req, like SetProxy,
+// do not change the others, pass the Transport of another Req to share
+// its connections and settings on purpose.
+func (r *Req) SetTransport(trans http.RoundTripper) {
+	r.Client().Transport = trans
+	r.reinstallPins()
 }
 
 func (r *Req) getTransport() *http.Transport {
@@ -64,26 +123,44 @@ func (r *Req) getTransport() *http.Transport {
 	return trans
 }
 
-// EnableInsecureTLS allows insecure https
-func (r *Req) EnableInsecureTLS(enable bool) {
+// setTransport calls set with the transport of the underlying http.Client
+func (r *Req) setTransport(set func(trans *http.Transport)) error {
 	trans := r.getTransport()
 	if trans == nil {
-		return
-	}
-	if trans.TLSClientConfig == nil {
-		trans.TLSClientConfig = &tls.Config{}
+		return errors.New("req: no transport")
 	}
-	trans.TLSClientConfig.InsecureSkipVerify = enable
+	set(trans)
+	return nil
 }
 
-// EnableCookieenable or disable cookie manager
-func (r *Req) EnableCookie(enable bool) {
-	if enable {
-		jar, _ := cookiejar.New(nil)
-		r.Client().Jar = jar
-	} else {
+// EnableInsecureTLS allows insecure https, the server certificates are
+// not verified, enabled by default unless VerifyTLS is set
+func (r *Req) EnableInsecureTLS(enable bool) {
+	_ = r.setTLSConfig(func(config *tls.Config) error {
+		config.InsecureSkipVerify = enable
+		return nil
+	})
+}
+
+// EnableCookie enable or disable cookie manager, the cookies are kept by
+// an in-memory *Jar, or by jar if given, like a *Jar saved to disk
+func (r *Req) EnableCookie(enable bool, jar ...http.CookieJar) {
+	if !enable {
 		r.Client().Jar = nil
+		return
+	}
+	if len(jar) > 0 && jar[0] != nil {
+		r.Client().Jar = jar[0]
+		return
 	}
+	r.Client().Jar, _ = NewJar(nil)
+}
+
+// CookieJar returns the *Jar keeping the cookies, nil if cookies are
+// disabled or kept by another http.CookieJar
+func (r *Req) CookieJar() *Jar {
+	jar, _ := r.Client().Jar.(*Jar)
+	return jar
 }
 
 // SetTimeout sets the timeout for every request
@@ -107,12 +184,81 @@ func (r *Req) SetProxyUrl(rawurl string) error {
 
 // SetProxy sets the proxy for every request
 func (r *Req) SetProxy(proxy func(*http.Request) (*url.URL, error)) error {
-	trans := r.getTransport()
-	if trans == nil {
-		return errors.New("req: no transport")
-	}
-	trans.Proxy = proxy
-	return nil
+	return r.setTransport(func(trans *http.Transport) {
+		trans.Proxy = proxy
+	})
+}
+
+// EnableKeepAlives enables or disables the reuse of connections, enabled
+// by default
+func (r *Req) EnableKeepAlives(enable bool) error {
+	return r.setTransport(func(trans *http.Transport) {
+		trans.DisableKeepAlives = !enable
+	})
+}
+
+// SetMaxIdleConns sets the maximum number of idle connections kept across
+// all hosts, 0 means no limit
+func (r *Req) SetMaxIdleConns(n int) error {
+	return r.setTransport(func(trans *http.Transport) {
+		trans.MaxIdleConns = n
+	})
+}
+
+// SetMaxIdleConnsPerHost sets the maximum number of idle connections kept
+// per host, 0 means http.DefaultMaxIdleConnsPerHost
+func (r *Req) SetMaxIdleConnsPerHost(n int) error {
+	return r.setTransport(func(trans *http.Transport) {
+		trans.MaxIdleConnsPerHost = n
+	})
+}
+
+// SetMaxConnsPerHost sets the maximum number of connections per host,
+// dialing, active and idle ones included, 0 means no limit
+func (r *Req) SetMaxConnsPerHost(n int) error {
+	return r.setTransport(func(trans *http.Transport) {
+		trans.MaxConnsPerHost = n
+	})
+}
+
+// SetIdleConnTimeout sets how long an idle connection is kept before
+// closing it, 0 means no limit
+func (r *Req) SetIdleConnTimeout(d time.Duration) error {
+	return r.setTransport(func(trans *http.Transport) {
+		trans.IdleConnTimeout = d
+	})
+}
+
+// SetDialTimeout sets the timeout for establishing a connection
+func (r *Req) SetDialTimeout(d time.Duration) error {
+	return r.setTransport(func(trans *http.Transport) {
+		trans.DialContext = newDialer(d).DialContext
+	})
+}
+
+// SetTLSHandshakeTimeout sets the timeout for the TLS handshake, 0 means
+// no timeout
+func (r *Req) SetTLSHandshakeTimeout(d time.Duration) error {
+	return r.setTransport(func(trans *http.Transport) {
+		trans.TLSHandshakeTimeout = d
+	})
+}
+
+// SetResponseHeaderTimeout sets how long to wait for the response headers
+// after the request is written, 0 means no timeout
+func (r *Req) SetResponseHeaderTimeout(d time.Duration) error {
+	return r.setTransport(func(trans *http.Transport) {
+		trans.ResponseHeaderTimeout = d
+	})
+}
+
+// SetExpectContinueTimeout sets how long to wait for the response headers
+// of a request with "Expect: 100-continue" before sending the body, 0
+// means the body is sent immediately
+func (r *Req) SetExpectContinueTimeout(d time.Duration) error {
+	return r.setTransport(func(trans *http.Transport) {
+		trans.ExpectContinueTimeout = d
+	})
 }
 
 type jsonEncOpts struct {
@@ -0,0 +1,147 @@
+package req
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransportPerReq(t *testing.T) {
+	r1, r2 := New(), New()
+	if r1.Transport() == r2.Transport() {
+		t.Fatal("two Req share a transport")
+	}
+	r1.EnableInsecureTLS(false)
+	if err := r1.SetProxyUrl("http://proxy.example.com:8080"); err != nil {
+		t.Fatal(err)
+	}
+	trans := r2.Transport().(*http.Transport)
+	if trans.Proxy != nil || !trans.TLSClientConfig.InsecureSkipVerify {
+		t.Error("the settings of a Req changed another Req")
+	}
+	if !httpTransport.TLSClientConfig.InsecureSkipVerify || httpTransport.Proxy != nil {
+		t.Error("the settings of a Req changed the default transport")
+	}
+
+	// shared on purpose
+	r2.SetTransport(r1.Transport())
+	if r2.Transport() != r1.Transport() {
+		t.Error("transport not shared")
+	}
+	r1.EnableInsecureTLS(true)
+	if !r2.Transport().(*http.Transport).TLSClientConfig.InsecureSkipVerify {
+		t.Error("the settings of a shared transport are not shared")
+	}
+}
+
+func TestKeepAlives(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	get := func(r *Req) (reused int) {
+		trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				reused++
+			}
+		}}
+		ctx := httptrace.WithClientTrace(context.Background(), trace)
+		for i := 0; i < 3; i++ {
+			resp, err := r.Get(ts.URL, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.ToBytes()
+		}
+		return
+	}
+	if n := get(New()); n != 2 {
+		t.Errorf("reused = %d; want = %d", n, 2)
+	}
+
+	r := New()
+	if err := r.EnableKeepAlives(false); err != nil {
+		t.Fatal(err)
+	}
+	if n := get(r); n != 0 {
+		t.Errorf("reused = %d; want = %d", n, 0)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportSettings(t *testing.T) {
+	r := New()
+	r.SetMaxIdleConns(20)
+	r.SetMaxIdleConnsPerHost(5)
+	r.SetMaxConnsPerHost(8)
+	r.SetIdleConnTimeout(time.Minute)
+	r.SetTLSHandshakeTimeout(3 * time.Second)
+	r.SetResponseHeaderTimeout(4 * time.Second)
+	r.SetExpectContinueTimeout(2 * time.Second)
+	trans := r.getTransport()
+	if trans.MaxIdleConns != 20 || trans.MaxIdleConnsPerHost != 5 || trans.MaxConnsPerHost != 8 {
+		t.Errorf("conns = %d, %d, %d; want = 20, 5, 8", trans.MaxIdleConns, trans.MaxIdleConnsPerHost, trans.MaxConnsPerHost)
+	}
+	if trans.IdleConnTimeout != time.Minute || trans.TLSHandshakeTimeout != 3*time.Second ||
+		trans.ResponseHeaderTimeout != 4*time.Second || trans.ExpectContinueTimeout != 2*time.Second {
+		t.Error("timeouts not set")
+	}
+
+	// the dial timeout applies to connecting, not to the response
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer ts.Close()
+	r.SetDialTimeout(time.Second)
+	if _, err := r.Get(ts.URL); err != nil {
+		t.Error(err)
+	}
+	r.SetResponseHeaderTimeout(10 * time.Millisecond)
+	if _, err := r.Get(ts.URL); err == nil {
+		t.Error("no response header timeout")
+	}
+
+	r.SetTransport(roundTripperFunc(nil))
+	if err := r.SetMaxIdleConns(1); err == nil {
+		t.Error("no error without *http.Transport")
+	}
+}
+
+func TestSharedTransport(t *testing.T) {
+	var conns int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	for i := 0; i < 20; i++ {
+		resp, err := Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.ToBytes()
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("connections = %d; want = %d", n, 1)
+	}
+	if New().Transport() == sharedTransport() {
+		t.Error("New shares the transport of the package level functions")
+	}
+}
@@ -0,0 +1,255 @@
+package req
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// setTLSConfig calls set with the TLS config of the transport, the idle
+// connections are closed so the next requests use the new config
+func (r *Req) setTLSConfig(set func(config *tls.Config) error) error {
+	var err error
+	e := r.setTransport(func(trans *http.Transport) {
+		if trans.TLSClientConfig == nil {
+			trans.TLSClientConfig = &tls.Config{}
+		}
+		if err = set(trans.TLSClientConfig); err == nil {
+			trans.CloseIdleConnections()
+		}
+	})
+	if e != nil {
+		return e
+	}
+	return err
+}
+
+// TLSConfig returns the TLS config of the transport, nil if the transport
+// is not an *http.Transport. Changes to it apply to new connections.
+func (r *Req) TLSConfig() *tls.Config {
+	trans := r.getTransport()
+	if trans == nil {
+		return nil
+	}
+	if trans.TLSClientConfig == nil {
+		trans.TLSClientConfig = &tls.Config{}
+	}
+	return trans.TLSClientConfig
+}
+
+// SetClientCertificates adds certificates presented to the servers asking
+// for a client certificate
+func (r *Req) SetClientCertificates(certs ...tls.Certificate) error {
+	return r.setTLSConfig(func(config *tls.Config) error {
+		config.Certificates = append(config.Certificates, certs...)
+		return nil
+	})
+}
+
+// SetClientCertPEM adds a client certificate from a PEM encoded
+// certificate chain and private key
+func (r *Req) SetClientCertPEM(certPEM, keyPEM []byte) error {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+	return r.SetClientCertificates(cert)
+}
+
+// SetClientCertFile adds a client certificate from PEM encoded files
+func (r *Req) SetClientCertFile(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	return r.SetClientCertificates(cert)
+}
+
+// SetClientCertPKCS12 adds a client certificate from PKCS#12 (.p12 or
+// .pfx) data, the CA certificates it contains complete the chain
+func (r *Req) SetClientCertPKCS12(data []byte, password string) error {
+	key, leaf, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return err
+	}
+	cert := tls.Certificate{PrivateKey: key, Leaf: leaf}
+	cert.Certificate = append(cert.Certificate, leaf.Raw)
+	for _, ca := range caCerts {
+		cert.Certificate = append(cert.Certificate, ca.Raw)
+	}
+	return r.SetClientCertificates(cert)
+}
+
+// SetClientCertPKCS12File adds a client certificate from a PKCS#12 file
+func (r *Req) SetClientCertPKCS12File(filename, password string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return r.SetClientCertPKCS12(data, password)
+}
+
+// SetRootCAs sets the root CAs verifying the server certificates, nil
+// means the CAs of the system
+func (r *Req) SetRootCAs(pool *x509.CertPool) error {
+	return r.setTLSConfig(func(config *tls.Config) error {
+		config.RootCAs = pool
+		return nil
+	})
+}
+
+// AddRootCA adds the PEM encoded root CAs to the CAs verifying the server
+// certificates, the CAs of the system are kept when available and a pool
+// given to SetRootCAs is modified in place. The server certificates are
+// verified only if verification is enabled, see EnableInsecureTLS and
+// VerifyTLS.
+func (r *Req) AddRootCA(pemCerts []byte) error {
+	return r.setTLSConfig(func(config *tls.Config) error {
+		pool := config.RootCAs
+		if pool == nil {
+			var err error
+			if pool, err = x509.SystemCertPool(); err != nil {
+				pool = x509.NewCertPool()
+			}
+		}
+		if !pool.AppendCertsFromPEM(pemCerts) {
+			return errors.New("req: no certificate found in PEM data")
+		}
+		config.RootCAs = pool
+		return nil
+	})
+}
+
+// AddRootCAFile adds the root CAs of a PEM encoded file, see AddRootCA
+func (r *Req) AddRootCAFile(filename string) error {
+	pemCerts, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return r.AddRootCA(pemCerts)
+}
+
+// SetTLSVersion sets the minimum and maximum TLS versions, like
+// tls.VersionTLS12, 0 means the default of crypto/tls
+func (r *Req) SetTLSVersion(min, max uint16) error {
+	if min != 0 && max != 0 && min > max {
+		return errors.New("req: minimum TLS version above maximum")
+	}
+	return r.setTLSConfig(func(config *tls.Config) error {
+		config.MinVersion = min
+		config.MaxVersion = max
+		return nil
+	})
+}
+
+// SetCipherSuites sets the cipher suites of TLS 1.2 and below, like
+// tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, the cipher suites of TLS 1.3
+// are not configurable
+func (r *Req) SetCipherSuites(suites ...uint16) error {
+	return r.setTLSConfig(func(config *tls.Config) error {
+		config.CipherSuites = suites
+		return nil
+	})
+}
+
+// SetServerName overrides the server name sent by SNI and verified against
+// the server certificate, "" means the host of the request
+func (r *Req) SetServerName(name string) error {
+	return r.setTLSConfig(func(config *tls.Config) error {
+		config.ServerName = name
+		return nil
+	})
+}
+
+// SetALPN sets the protocols negotiated by ALPN, in order of preference.
+// HTTP/2 is used when "h2" is listed and the server accepts it.
+func (r *Req) SetALPN(protos ...string) error {
+	if containsString(protos, "h2") {
+		if err := r.setTransport(func(trans *http.Transport) {
+			_ = configureHTTP2(trans)
+		}); err != nil {
+			return err
+		}
+	}
+	return r.setTLSConfig(func(config *tls.Config) error {
+		config.NextProtos = protos
+		return nil
+	})
+}
+
+// configureHTTP2 sets up HTTP/2 on trans unless it already is
+func configureHTTP2(trans *http.Transport) error {
+	if trans.TLSNextProto["h2"] != nil {
+		return nil
+	}
+	_, err := http2.ConfigureTransports(trans)
+	return err
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// TLS returns the state of the TLS connection the response was received
+// on, nil if the connection was not encrypted.
+func (r *Resp) TLS() *tls.ConnectionState {
+	if r.resp == nil {
+		return nil
+	}
+	return r.resp.TLS
+}
+
+// TLSVersion returns the negotiated TLS version, like tls.VersionTLS13, 0
+// if the connection was not encrypted.
+func (r *Resp) TLSVersion() uint16 {
+	if cs := r.TLS(); cs != nil {
+		return cs.Version
+	}
+	return 0
+}
+
+// CipherSuite returns the negotiated cipher suite, see tls.CipherSuiteName,
+// 0 if the connection was not encrypted.
+func (r *Resp) CipherSuite() uint16 {
+	if cs := r.TLS(); cs != nil {
+		return cs.CipherSuite
+	}
+	return 0
+}
+
+// NegotiatedProtocol returns the protocol negotiated by ALPN, like "h2",
+// "" if none was.
+func (r *Resp) NegotiatedProtocol() string {
+	if cs := r.TLS(); cs != nil {
+		return cs.NegotiatedProtocol
+	}
+	return ""
+}
+
+// PeerCertificates returns the certificate chain presented by the server,
+// the server certificate first.
+func (r *Resp) PeerCertificates() []*x509.Certificate {
+	if cs := r.TLS(); cs != nil {
+		return cs.PeerCertificates
+	}
+	return nil
+}
+
+// OCSPResponse returns the OCSP response stapled by the server, nil if
+// none was.
+func (r *Resp) OCSPResponse() []byte {
+	if cs := r.TLS(); cs != nil {
+		return cs.OCSPResponse
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package req
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key, Leaf: c.cert}
+}
+
+// newTestCert returns a certificate signed by parent, or a self signed CA
+// if parent is nil
+func newTestCert(t *testing.T, parent *testCert, dnsNames ...string) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "req test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     dnsNames,
+	}
+	parentCert, parentKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// newTLSServer starts a server presenting cert and reporting the TLS
+// parameters of the connection
+func newTLSServer(cert *testCert, config func(*tls.Config)) *httptest.Server {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%x %x %s %s", r.TLS.Version, r.TLS.CipherSuite, r.TLS.ServerName, r.Proto)
+	}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert.tlsCertificate()}}
+	if config != nil {
+		config(ts.TLS)
+	}
+	ts.StartTLS()
+	return ts
+}
+
+func TestTLSVerify(t *testing.T) {
+	ca := newTestCert(t, nil)
+	ts := newTLSServer(newTestCert(t, ca, "example.test"), nil)
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	url := "https://localhost:" + port
+
+	// not verified by default
+	r := New()
+	if _, err := r.Get(url); err != nil {
+		t.Fatal(err)
+	}
+
+	r.EnableInsecureTLS(false)
+	if _, err := r.Get(url); err == nil {
+		t.Error("unknown authority accepted")
+	}
+	if err := r.AddRootCA(ca.certPEM); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get(url); err == nil {
+		t.Error("certificate of another host accepted")
+	}
+	if err := r.SetServerName("example.test"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := r.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var version, suite, serverName, proto string
+	fmt.Sscan(resp.String(), &version, &suite, &serverName, &proto)
+	if serverName != "example.test" {
+		t.Errorf("server name = %q; want = %q", serverName, "example.test")
+	}
+
+	if err := r.AddRootCA([]byte("no certificate")); err == nil {
+		t.Error("no error without certificate")
+	}
+
+	VerifyTLS = true
+	defer func() { VerifyTLS = false }()
+	if _, err := New().Get(url); err == nil {
+		t.Error("not verified with VerifyTLS")
+	}
+}
+
+func TestTLSClientCert(t *testing.T) {
+	ca := newTestCert(t, nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	ts := newTLSServer(newTestCert(t, ca), func(config *tls.Config) {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = pool
+	})
+	defer ts.Close()
+	client := newTestCert(t, ca)
+
+	if _, err := New().Get(ts.URL); err == nil {
+		t.Error("no error without client certificate")
+	}
+
+	r := New()
+	if err := r.SetClientCertPEM(client.certPEM, client.keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get(ts.URL); err != nil {
+		t.Error(err)
+	}
+
+	p12, err := pkcs12.Encode(rand.Reader, client.key, client.cert, []*x509.Certificate{ca.cert}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = New()
+	if err := r.SetClientCertPKCS12(p12, "wrong"); err == nil {
+		t.Error("no error with a wrong password")
+	}
+	if err := r.SetClientCertPKCS12(p12, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(r.TLSConfig().Certificates[0].Certificate); n != 2 {
+		t.Errorf("chain = %d; want = %d", n, 2)
+	}
+	if _, err := r.Get(ts.URL); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTLSVersionAndCipherSuites(t *testing.T) {
+	ts := newTLSServer(newTestCert(t, nil), nil)
+	defer ts.Close()
+
+	r := New()
+	if err := r.SetTLSVersion(tls.VersionTLS13, tls.VersionTLS12); err == nil {
+		t.Error("no error with min above max")
+	}
+	if err := r.SetTLSVersion(tls.VersionTLS12, tls.VersionTLS12); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetCipherSuites(tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := r.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var version, suite string
+	fmt.Sscan(resp.String(), &version, &suite)
+	if want := fmt.Sprintf("%x", tls.VersionTLS12); version != want {
+		t.Errorf("version = %s; want = %s", version, want)
+	}
+	if want := fmt.Sprintf("%x", tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305); suite != want {
+		t.Errorf("cipher suite = %s; want = %s", suite, want)
+	}
+}
+
+func TestALPN(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	r := New()
+	proto := func() string {
+		resp, err := r.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.String()
+	}
+	if p := proto(); p != "HTTP/1.1" {
+		t.Errorf("proto = %s; want = %s", p, "HTTP/1.1")
+	}
+	if err := r.SetALPN("h2", "http/1.1"); err != nil {
+		t.Fatal(err)
+	}
+	if p := proto(); p != "HTTP/2.0" {
+		t.Errorf("proto = %s; want = %s", p, "HTTP/2.0")
+	}
+	if err := r.SetALPN("http/1.1"); err != nil {
+		t.Fatal(err)
+	}
+	if p := proto(); p != "HTTP/1.1" {
+		t.Errorf("proto = %s; want = %s", p, "HTTP/1.1")
+	}
+
+	// the idle HTTP/2 connections are closed when the TLS config changes
+	r = New()
+	r.SetALPN("h2")
+	if p := proto(); p != "HTTP/2.0" {
+		t.Errorf("proto = %s; want = %s", p, "HTTP/2.0")
+	}
+	r.EnableInsecureTLS(false)
+	if _, err := r.Get(ts.URL); err == nil {
+		t.Error("idle HTTP/2 connection reused after the TLS config changed")
+	}
+
+	// a transport without HTTP/2
+	r = New()
+	r.SetTransport(&http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}})
+	r.SetALPN("h2", "http/1.1")
+	if p := proto(); p != "HTTP/2.0" {
+		t.Errorf("proto = %s; want = %s", p, "HTTP/2.0")
+	}
+}
+
+func TestRespTLS(t *testing.T) {
+	ca := newTestCert(t, nil)
+	leaf := newTestCert(t, ca, "localhost")
+	cert := leaf.tlsCertificate()
+	cert.Certificate = append(cert.Certificate, ca.cert.Raw)
+	cert.OCSPStaple = []byte("ocsp staple")
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	r := New()
+	r.SetALPN("h2")
+	r.SetTLSVersion(tls.VersionTLS13, 0)
+	resp, err := r.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TLS() == nil {
+		t.Fatal("TLS = nil")
+	}
+	if v := resp.TLSVersion(); v != tls.VersionTLS13 {
+		t.Errorf("TLS version = %x; want = %x", v, tls.VersionTLS13)
+	}
+	if resp.CipherSuite() == 0 {
+		t.Error("cipher suite = 0")
+	}
+	if proto := resp.NegotiatedProtocol(); proto != "h2" {
+		t.Errorf("negotiated protocol = %s; want = %s", proto, "h2")
+	}
+	certs := resp.PeerCertificates()
+	if len(certs) != 2 || !certs[0].Equal(leaf.cert) || !certs[1].Equal(ca.cert) {
+		t.Errorf("peer certificates = %d; want the server certificate and its CA", len(certs))
+	}
+	if staple := string(resp.OCSPResponse()); staple != "ocsp staple" {
+		t.Errorf("OCSP response = %q; want = %q", staple, "ocsp staple")
+	}
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+	resp, err = r.Get(plain.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TLS() != nil || resp.TLSVersion() != 0 || resp.PeerCertificates() != nil {
+		t.Error("TLS state of a plain connection")
+	}
+}
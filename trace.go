@@ -0,0 +1,185 @@
+package req
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TraceInfo holds the timings of the last attempt of a request.
+type TraceInfo struct {
+	// DNSLookup is the time spent resolving the host name.
+	DNSLookup time.Duration
+	// TCPConnect is the time spent establishing the TCP connection.
+	TCPConnect time.Duration
+	// TLSHandshake is the time spent on the TLS handshake.
+	TLSHandshake time.Duration
+	// FirstByte is the time from the start of the request to the first
+	// byte of the response.
+	FirstByte time.Duration
+	// BodyRead is the time spent reading the response body,
+	// 0 until the whole body is read.
+	BodyRead time.Duration
+	// Total is the time from the start of the request to the end of the
+	// response body, or to the response head if the body is not read yet.
+	Total time.Duration
+}
+
+func (t TraceInfo) String() string {
+	return fmt.Sprintf("dns lookup: %v, tcp connect: %v, tls handshake: %v, first byte: %v, body read: %v, total: %v",
+		t.DNSLookup, t.TCPConnect, t.TLSHandshake, t.FirstByte, t.BodyRead, t.Total)
+}
+
+// tracer records the timings of one attempt of a request. The
+// httptrace hooks may be called from other goroutines.
+type tracer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	gotResponse  time.Time
+	bodyDone     time.Time
+
+	// connection of the attempt
+	reused     bool
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func newTracer() *tracer {
+	return &tracer{start: time.Now()}
+}
+
+func (t *tracer) set(field *time.Time, keepFirst bool) {
+	t.mu.Lock()
+	if !keepFirst || field.IsZero() {
+		*field = time.Now()
+	}
+	t.mu.Unlock()
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.set(&t.dnsStart, false) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone, false) },
+		// with multiple addresses, several connections may be attempted
+		ConnectStart:         func(network, addr string) { t.set(&t.connectStart, true) },
+		ConnectDone:          func(network, addr string, err error) { t.set(&t.connectDone, false) },
+		TLSHandshakeStart:    func() { t.set(&t.tlsStart, false) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.set(&t.tlsDone, false) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte, false) },
+		GotConn:              t.setConn,
+	}
+}
+
+func (t *tracer) setConn(info httptrace.GotConnInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reused = info.Reused
+	if info.Conn != nil {
+		t.localAddr = info.Conn.LocalAddr()
+		t.remoteAddr = info.Conn.RemoteAddr()
+	}
+}
+
+// traceBody records when the response body has been read.
+type traceBody struct {
+	io.ReadCloser
+	t *tracer
+}
+
+func (b *traceBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.t.set(&b.t.bodyDone, true)
+	}
+	return n, err
+}
+
+// trace returns a copy of req recording its timings. The header is
+// copied as well, since http.Client adds the cookies of its jar to it.
+func (t *tracer) trace(req *http.Request) *http.Request {
+	return req.Clone(httptrace.WithClientTrace(req.Context(), t.clientTrace()))
+}
+
+// traceResponse records that the response head was received.
+func (t *tracer) traceResponse(response *http.Response) {
+	t.set(&t.gotResponse, false)
+	response.Body = &traceBody{ReadCloser: response.Body, t: t}
+}
+
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+func (t *tracer) info() TraceInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info := TraceInfo{
+		DNSLookup:    between(t.dnsStart, t.dnsDone),
+		TCPConnect:   between(t.connectStart, t.connectDone),
+		TLSHandshake: between(t.tlsStart, t.tlsDone),
+		FirstByte:    between(t.start, t.firstByte),
+		BodyRead:     between(t.gotResponse, t.bodyDone),
+	}
+	if !t.bodyDone.IsZero() {
+		info.Total = t.bodyDone.Sub(t.start)
+	} else {
+		info.Total = between(t.start, t.gotResponse)
+	}
+	return info
+}
+
+// TraceInfo returns the timings of the last attempt of the request.
+func (r *Resp) TraceInfo() TraceInfo {
+	if r.trace == nil {
+		return TraceInfo{}
+	}
+	return r.trace.info()
+}
+
+// ConnReused reports whether the last attempt of the request reused a
+// previous connection.
+func (r *Resp) ConnReused() bool {
+	if r.trace == nil {
+		return false
+	}
+	r.trace.mu.Lock()
+	defer r.trace.mu.Unlock()
+	return r.trace.reused
+}
+
+// LocalAddr returns the local address of the connection of the last
+// attempt of the request, nil if unknown.
+func (r *Resp) LocalAddr() net.Addr {
+	if r.trace == nil {
+		return nil
+	}
+	r.trace.mu.Lock()
+	defer r.trace.mu.Unlock()
+	return r.trace.localAddr
+}
+
+// RemoteAddr returns the remote address of the connection of the last
+// attempt of the request, nil if unknown.
+func (r *Resp) RemoteAddr() net.Addr {
+	if r.trace == nil {
+		return nil
+	}
+	r.trace.mu.Lock()
+	defer r.trace.mu.Unlock()
+	return r.trace.remoteAddr
+}
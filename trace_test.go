@@ -0,0 +1,72 @@
+package req
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceInfo(t *testing.T) {
+	delay := 20 * time.Millisecond
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(delay)
+		_, _ = w.Write([]byte("response body"))
+	}
+	ts := httptest.NewTLSServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	r := New()
+	r.flag |= Lcost
+	resp, err := r.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := resp.TraceInfo()
+	if info.TCPConnect <= 0 || info.TLSHandshake <= 0 {
+		t.Errorf("tcp connect = %v, tls handshake = %v; want > 0", info.TCPConnect, info.TLSHandshake)
+	}
+	if info.FirstByte < delay {
+		t.Errorf("first byte = %v; want >= %v", info.FirstByte, delay)
+	}
+	if resp.Cost() < delay {
+		t.Errorf("cost = %v; want >= %v", resp.Cost(), delay)
+	}
+
+	resp.Bytes()
+	info = resp.TraceInfo()
+	if info.BodyRead < delay || info.Total < 2*delay {
+		t.Errorf("body read = %v, total = %v; want >= %v, %v", info.BodyRead, info.Total, delay, 2*delay)
+	}
+	if dump := resp.Dump(); !strings.Contains(dump, "tls handshake: ") {
+		t.Errorf("dump lacks the timings: %s", dump)
+	}
+}
+
+func TestConnInfo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.RemoteAddr))
+	}))
+	defer ts.Close()
+
+	r := New()
+	for i, reused := range []bool{false, true} {
+		resp, err := r.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.ConnReused() != reused {
+			t.Errorf("request %d: reused = %v; want = %v", i, resp.ConnReused(), reused)
+		}
+		if addr := resp.RemoteAddr(); addr == nil || addr.String() != ts.Listener.Addr().String() {
+			t.Errorf("remote addr = %v; want = %v", addr, ts.Listener.Addr())
+		}
+		if addr := resp.LocalAddr(); addr == nil || addr.String() != resp.String() {
+			t.Errorf("local addr = %v; want = %s", addr, resp.String())
+		}
+	}
+}